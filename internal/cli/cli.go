@@ -2,17 +2,25 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	"folder-tail/internal/output"
+	"folder-tail/internal/server"
 	"folder-tail/internal/tailer"
 	"folder-tail/internal/tui"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
 )
 
 const defaultMaxLines = 10000
@@ -22,7 +30,7 @@ func Run(args []string, version string) int {
 	fs.SetOutput(os.Stderr)
 	fs.Usage = func() {
 		out := fs.Output()
-		fmt.Fprintln(out, "Usage: ft [root] [pattern ...]")
+		fmt.Fprintln(out, "Usage: ft [path ...] [pattern ...]")
 		fmt.Fprintln(out, "")
 		fs.PrintDefaults()
 		fmt.Fprintln(out, "")
@@ -31,6 +39,7 @@ func Run(args []string, version string) int {
 		fmt.Fprintln(out, "  ft ./*.log")
 		fmt.Fprintln(out, "  ft /var/log '*.log'")
 		fmt.Fprintln(out, "  ft -re /var/log '.*\\\\.log$'")
+		fmt.Fprintln(out, "  ft /var/log/nginx app=/srv/app/logs ./debug.log")
 	}
 
 	var (
@@ -47,7 +56,43 @@ func Run(args []string, version string) int {
 		forceRegex2  = fs.Bool("regex", false, "treat patterns as regular expressions")
 		recursive    = fs.Bool("r", true, "recursive (default true)")
 		recursive2   = fs.Bool("R", true, "recursive (default true)")
+		resume       = fs.Bool("resume", false, "resume each file from its saved checkpoint instead of the last -n lines")
+		stateFile    = fs.String("state-file", "", "checkpoint file for -resume (default: user cache dir)")
+		checkpoint   = fs.Duration("checkpoint-interval", 5*time.Second, "how often checkpoints are written")
+		grepFixed    = fs.Bool("F", false, "treat -grep/-grep-v patterns as literal strings")
+		ignoreCase   = fs.Bool("i", false, "case-insensitive -grep/-grep-v matching")
+		after        = fs.Int("A", 0, "lines of trailing context after each -grep match")
+		before       = fs.Int("B", 0, "lines of leading context before each -grep match")
+		contextLines = fs.Int("C", 0, "lines of context around each -grep match (sets -A and -B)")
+		plain        = fs.Bool("plain", false, "stream lines to stdout instead of the TUI (same as -output plain)")
+		outputMode   = fs.String("output", "", "output mode: tui, plain, or json (default tui, or plain when stdout is not a terminal)")
+		prefix       = fs.Bool("prefix", false, "prefix each line with its path instead of grouping under path headers")
+		overflow     = fs.String("overflow", "drop-oldest", "what to do when the line queue is full: block, drop-oldest or drop-newest")
+		watchMode    = fs.String("watch", "auto", "change detection: auto, fsnotify or poll (auto polls directories fsnotify cannot watch)")
+		followLinks  = fs.Bool("follow-symlinks", false, "follow symlinks to files and directories")
+		order        = fs.String("order", "arrival", "line order across files: arrival, or time (merge by parsed timestamps)")
+		timeLayout   = fs.String("time-layout", "", "Go time layout for leading timestamps not recognized by -order time")
+		reorderDelay = fs.Duration("reorder-window", time.Second, "how long -order time holds live lines to sort them")
+		mlStart      = fs.String("multiline-start", "", "regex matching the first line of a record; other lines are appended to it")
+		mlIndent     = fs.Bool("multiline-indent", false, "treat lines starting with whitespace as continuations of the previous line")
+		mlTimeout    = fs.Duration("multiline-timeout", 500*time.Millisecond, "emit a record after this long without a new line")
+		rotated      = fs.Bool("rotated", false, "fill the -n backlog from rotated siblings (app.log.1, app.log.2.gz, ...) when the current file is short")
+		ansiMode     = fs.String("ansi", "", "escape sequences in lines: keep, strip, or sanitize (keep colors, show other escapes as ^[...; default for the TUI and plain output, while json keeps the text as is)")
+		minLevel     = fs.String("level", "", "only show lines at or above this level: trace, debug, info, warn, error, or fatal (lines without a level are kept)")
+		fieldFormat  = fs.String("fields", "auto", "parse lines into fields: auto (JSON objects and logfmt), json, logfmt, or off")
+		columns      = fs.String("columns", "time|ts|timestamp|@timestamp level|lvl|severity msg|message", "fields the TUI shows for structured lines (space-separated; a|b shows the first present)")
+		where        = fs.String("where", "", "only show lines matching this field expression, e.g. 'level>=warn and service==\"api\" and latency_ms>500'")
+		listen       = fs.String("listen", "", "also serve a web UI and line streams over HTTP on this address, without authentication (e.g. 127.0.0.1:8080; :8080 exposes logs on every interface)")
+		pollInterval = fs.Duration("poll-interval", time.Second, "how often polled directories are checked")
+		grep         listFlag
+		grepExclude  listFlag
+		encodings    listFlag
+		allowOrigins listFlag
 	)
+	fs.Var(&grep, "grep", "only show lines matching this regex (repeatable)")
+	fs.Var(&grepExclude, "grep-v", "hide lines matching this regex (repeatable)")
+	fs.Var(&allowOrigins, "allow-origin", "origin (e.g. https://dash.example.com) whose pages may open -listen WebSocket streams (repeatable; default: same host only)")
+	fs.Var(&encodings, "encoding", "file encoding, e.g. latin1 or shift_jis, or pattern=encoding for matching files (repeatable; default: detect)")
 
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
@@ -64,47 +109,144 @@ func Run(args []string, version string) int {
 
 	isRecursive := *recursive && *recursive2
 
-	root := "."
-	patterns := fs.Args()
-	if len(patterns) > 0 {
-		if info, err := os.Stat(patterns[0]); err == nil && info.IsDir() {
-			root = patterns[0]
-			patterns = patterns[1:]
+	sources, patterns := splitSources(fs.Args())
+	if len(sources) == 0 {
+		sources = []tailer.Source{{Path: "."}}
+	}
+
+	if *contextLines > 0 {
+		if *after == 0 {
+			*after = *contextLines
+		}
+		if *before == 0 {
+			*before = *contextLines
+		}
+	}
+
+	mode := *outputMode
+	if mode == "" {
+		switch {
+		case *plain:
+			mode = "plain"
+		case isTerminal(os.Stdout):
+			mode = "tui"
+		default:
+			mode = "plain"
 		}
 	}
+	if mode != "tui" && mode != "plain" && mode != "json" {
+		fmt.Fprintln(os.Stderr, "invalid -output mode:", mode)
+		return 2
+	}
 
-	absRoot, err := filepath.Abs(root)
+	overflowPolicy, err := tailer.ParseOverflowPolicy(*overflow)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return 1
+		return 2
 	}
-	root = absRoot
 
-	info, err := os.Stat(root)
+	watch, err := tailer.ParseWatchMode(*watchMode)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return 1
+		return 2
 	}
-	if !info.IsDir() {
-		fmt.Fprintln(os.Stderr, "root is not a directory:", root)
-		return 1
+
+	lineOrder, err := tailer.ParseOrder(*order)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ansi, err := tailer.ParseANSIMode(*ansiMode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *ansiMode == "" && mode == "json" {
+		// JSON escapes control characters itself, so consumers get the file's text.
+		ansi = tailer.ANSIKeep
+	}
+
+	level, err := tailer.ParseLevel(*minLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	fields, err := tailer.ParseFieldFormat(*fieldFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var whereQuery *tailer.Query
+	if *where != "" {
+		whereQuery, err = tailer.ParseQuery(*where)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid where expression:", err)
+			var queryErr *tailer.QueryError
+			if errors.As(err, &queryErr) {
+				fmt.Fprintln(os.Stderr, "  "+strings.ReplaceAll(queryErr.Context(), "\n", "\n  "))
+			}
+			return 2
+		}
+	}
+
+	checkpointPath := *stateFile
+	if checkpointPath == "" && *resume {
+		checkpointPath, err = defaultStateFile()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
 	}
 
 	includePatterns := append(parseList(*include), patterns...)
 	excludePatterns := parseList(*exclude)
 
 	cfg := tailer.Config{
-		Root:         root,
-		N:            *lines,
-		FromStart:    *fromStart,
-		ScanInterval: *scanInterval,
-		Absolute:     *absolute,
-		Include:      includePatterns,
-		Exclude:      excludePatterns,
-		ForceRegex:   *forceRegex || *forceRegex2,
-		Recursive:    isRecursive,
-		RecursiveSet: true,
-		MaxLineBytes: *maxLineBytes,
+		Sources:            sources,
+		N:                  *lines,
+		FromStart:          *fromStart,
+		ScanInterval:       *scanInterval,
+		Absolute:           *absolute,
+		Include:            includePatterns,
+		Exclude:            excludePatterns,
+		ForceRegex:         *forceRegex || *forceRegex2,
+		Recursive:          isRecursive,
+		RecursiveSet:       true,
+		MaxLineBytes:       *maxLineBytes,
+		CheckpointPath:     checkpointPath,
+		CheckpointInterval: *checkpoint,
+		Resume:             *resume,
+		Grep:               grep,
+		GrepExclude:        grepExclude,
+		GrepFixed:          *grepFixed,
+		GrepIgnoreCase:     *ignoreCase,
+		GrepBefore:         *before,
+		GrepAfter:          *after,
+		Overflow:           overflowPolicy,
+		Watch:              watch,
+		FollowSymlinks:     *followLinks,
+		Order:              lineOrder,
+		TimeLayout:         *timeLayout,
+		ReorderWindow:      *reorderDelay,
+		MultilineStart:     *mlStart,
+		MultilineIndent:    *mlIndent,
+		MultilineTimeout:   *mlTimeout,
+		RotatedBacklog:     *rotated,
+		Encoding:           encodings,
+		ANSI:               ansi,
+		PollInterval:       *pollInterval,
+		MinLevel:           level,
+		Fields:             fields,
+		Where:              *where,
+	}
+	if mode == "tui" {
+		// The TUI applies the level and where expression itself so that v and
+		// w can widen them again.
+		cfg.MinLevel = tailer.LevelUnknown
+		cfg.Where = ""
 	}
 
 	t, err := tailer.New(cfg)
@@ -113,6 +255,20 @@ func Run(args []string, version string) int {
 		return 1
 	}
 
+	if *listen != "" {
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		srv := &http.Server{Handler: server.New(t, allowOrigins), ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(ln)
+		defer srv.Close()
+		if mode != "tui" {
+			fmt.Fprintf(os.Stderr, "ft: serving on http://%s\n", ln.Addr())
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -121,14 +277,33 @@ func Run(args []string, version string) int {
 		return 1
 	}
 
+	switch mode {
+	case "plain":
+		return runStream(cancel, t, &output.PlainFormatter{Prefix: *prefix})
+	case "json":
+		return runStream(cancel, t, output.JSONFormatter{})
+	}
+
+	roots := make([]string, 0, len(sources))
+	for _, src := range sources {
+		roots = append(roots, src.Path)
+	}
+
 	model := tui.New(tui.Config{
-		Root:       cfg.Root,
+		Root:       strings.Join(roots, ","),
 		Absolute:   cfg.Absolute,
 		Include:    cfg.Include,
 		Exclude:    cfg.Exclude,
 		ForceRegex: cfg.ForceRegex,
 		MaxLines:   *maxLines,
-	}, t.Lines(), t.Errors(), t.FileCount)
+		Grep:       cfg.Grep,
+		GrepV:      cfg.GrepExclude,
+		Prefix:     *prefix,
+		MinLevel:   level,
+		Where:      whereQuery,
+		Columns:    *columns,
+		Listen:     *listen,
+	}, t.Lines(), t.Errors(), t)
 
 	program := tea.NewProgram(model, tea.WithAltScreen())
 	if _, err := program.Run(); err != nil {
@@ -141,6 +316,69 @@ func Run(args []string, version string) int {
 	return 0
 }
 
+func runStream(cancel context.CancelFunc, t *tailer.Tailer, formatter output.Formatter) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-t.Done():
+		}
+	}()
+	go func() {
+		for err := range t.Errors() {
+			fmt.Fprintln(os.Stderr, "ft:", err)
+		}
+	}()
+
+	err := output.Stream(os.Stdout, t.Lines(), formatter)
+	cancel()
+	<-t.Done()
+	if err != nil && !errors.Is(err, syscall.EPIPE) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func isTerminal(file *os.File) bool {
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+}
+
+// splitSources treats every argument naming an existing file or directory as a
+// source and the rest as patterns. "label=path" gives a source a display label.
+func splitSources(args []string) ([]tailer.Source, []string) {
+	var sources []tailer.Source
+	var patterns []string
+	for _, arg := range args {
+		if _, err := os.Stat(arg); err == nil {
+			sources = append(sources, tailer.Source{Path: arg})
+			continue
+		}
+		if label, path, ok := strings.Cut(arg, "="); ok && label != "" {
+			if _, err := os.Stat(path); err == nil {
+				sources = append(sources, tailer.Source{Path: path, Label: label})
+				continue
+			}
+		}
+		patterns = append(patterns, arg)
+	}
+	return sources, patterns
+}
+
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func parseList(value string) []string {
 	if value == "" {
 		return nil
@@ -156,3 +394,11 @@ func parseList(value string) []string {
 	}
 	return out
 }
+
+func defaultStateFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ft", "checkpoints.json"), nil
+}
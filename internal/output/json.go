@@ -0,0 +1,72 @@
+package output
+
+import (
+	"encoding/json"
+	"time"
+
+	"folder-tail/internal/tailer"
+)
+
+type jsonLine struct {
+	Path      string     `json:"path"`
+	AbsPath   string     `json:"abs_path"`
+	Text      string     `json:"text"`
+	Offset    int64      `json:"offset"`
+	Line      int64      `json:"line,omitempty"`
+	Time      time.Time  `json:"time"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Level     string     `json:"level,omitempty"`
+	Fields    jsonFields `json:"fields,omitempty"`
+	Partial   bool       `json:"partial"`
+	Update    bool       `json:"update"`
+	Marker    bool       `json:"marker,omitempty"`
+}
+
+// jsonFields keeps the order fields had in the line.
+type jsonFields tailer.Fields
+
+func (f jsonFields) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, field := range f {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+	return append(buf, '}'), nil
+}
+
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(line tailer.Line) []byte {
+	var stamp *time.Time
+	if !line.Timestamp.IsZero() {
+		stamp = &line.Timestamp
+	}
+	data, err := json.Marshal(jsonLine{
+		Path:      line.Path,
+		AbsPath:   line.AbsPath,
+		Text:      line.Text,
+		Offset:    line.Offset,
+		Line:      line.LineNo,
+		Time:      line.Time,
+		Timestamp: stamp,
+		Level:     line.Level.String(),
+		Fields:    jsonFields(line.Fields),
+		Partial:   line.Partial,
+		Update:    line.Update,
+		Marker:    line.Marker,
+	})
+	if err != nil {
+		return nil
+	}
+	return append(data, '\n')
+}
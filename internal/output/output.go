@@ -0,0 +1,59 @@
+package output
+
+import (
+	"bufio"
+	"io"
+
+	"folder-tail/internal/tailer"
+)
+
+type Formatter interface {
+	Format(line tailer.Line) []byte
+}
+
+func Stream(w io.Writer, lines <-chan tailer.Line, formatter Formatter) error {
+	out := bufio.NewWriter(w)
+	for line := range lines {
+		if data := formatter.Format(line); len(data) > 0 {
+			if _, err := out.Write(data); err != nil {
+				return err
+			}
+		}
+		if len(lines) == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return out.Flush()
+}
+
+type PlainFormatter struct {
+	Prefix   bool
+	lastPath string
+	started  bool
+}
+
+func (f *PlainFormatter) Format(line tailer.Line) []byte {
+	if line.Partial {
+		return nil
+	}
+	var out []byte
+	if f.Prefix {
+		if line.Path != "" {
+			out = append(out, line.Path...)
+			out = append(out, ": "...)
+		}
+	} else if line.Path != "" && (line.Path != f.lastPath || !f.started) {
+		if f.started {
+			out = append(out, '\n')
+		}
+		out = append(out, "==> "...)
+		out = append(out, line.Path...)
+		out = append(out, " <==\n"...)
+		f.lastPath = line.Path
+	}
+	f.started = true
+	out = append(out, line.Text...)
+	return append(out, '\n')
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"folder-tail/internal/tailer"
+)
+
+func TestPlainGrouped(t *testing.T) {
+	lines := make(chan tailer.Line, 10)
+	lines <- tailer.Line{Path: "a.log", Text: "one"}
+	lines <- tailer.Line{Path: "a.log", Text: "tw", Partial: true}
+	lines <- tailer.Line{Path: "a.log", Text: "two", Update: true}
+	lines <- tailer.Line{Path: "b.log", Text: "three"}
+	lines <- tailer.Line{Path: "a.log", Text: "four"}
+	close(lines)
+
+	var buf bytes.Buffer
+	if err := Stream(&buf, lines, &PlainFormatter{}); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	want := "==> a.log <==\none\ntwo\n\n==> b.log <==\nthree\n\n==> a.log <==\nfour\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestPlainPrefix(t *testing.T) {
+	lines := make(chan tailer.Line, 10)
+	lines <- tailer.Line{Path: "a.log", Text: "one"}
+	lines <- tailer.Line{Path: "b.log", Text: "pending", Partial: true}
+	lines <- tailer.Line{Path: "b.log", Text: "two"}
+	close(lines)
+
+	var buf bytes.Buffer
+	if err := Stream(&buf, lines, &PlainFormatter{Prefix: true}); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	want := "a.log: one\nb.log: two\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	ts := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	lines := make(chan tailer.Line, 10)
+	lines <- tailer.Line{Path: "a.log", AbsPath: "/logs/a.log", Text: "hi \"there\"", Offset: 12, LineNo: 3, Time: ts, Fields: tailer.Fields{{Key: "b", Value: "1"}, {Key: "a", Value: true}}}
+	lines <- tailer.Line{Path: "a.log", AbsPath: "/logs/a.log", Text: "par", Offset: 20, Time: ts, Level: tailer.LevelWarn, Partial: true}
+	close(lines)
+
+	var buf bytes.Buffer
+	if err := Stream(&buf, lines, JSONFormatter{}); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	want := `{"path":"a.log","abs_path":"/logs/a.log","text":"hi \"there\"","offset":12,"line":3,"time":"2026-10-16T12:00:00Z","fields":{"b":"1","a":true},"partial":false,"update":false}` + "\n" +
+		`{"path":"a.log","abs_path":"/logs/a.log","text":"par","offset":20,"time":"2026-10-16T12:00:00Z","level":"warn","partial":true,"update":false}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,246 @@
+// Package server shares a live tail over HTTP: a small web UI, Server-Sent
+// Events and WebSocket streams of lines as JSON, and the tracked file list.
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"folder-tail/internal/output"
+	"folder-tail/internal/tailer"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+const heartbeatInterval = 15 * time.Second
+
+// clientBuffer is how many lines a client may fall behind before it loses
+// the oldest ones.
+const clientBuffer = 1024
+
+type Source interface {
+	Files() []tailer.FileStat
+	Subscribe(filter tailer.Filter) (<-chan tailer.Line, func())
+}
+
+type Server struct {
+	source         Source
+	allowedOrigins []string
+	mux            *http.ServeMux
+}
+
+// New serves source. WebSocket upgrades from pages on another host are
+// refused unless their origin is listed in allowedOrigins.
+func New(source Source, allowedOrigins []string) *Server {
+	s := &Server{source: source, allowedOrigins: allowedOrigins, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /files", s.handleFiles)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	s.mux.HandleFunc("GET /ws", s.handleWebSocket)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+type fileJSON struct {
+	Path         string    `json:"path"`
+	AbsPath      string    `json:"abs_path"`
+	Lines        int64     `json:"lines"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	files := []fileJSON{}
+	for _, file := range s.source.Files() {
+		files = append(files, fileJSON{Path: file.Path, AbsPath: file.AbsPath, Lines: file.Lines, LastActivity: file.LastActivity})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+// parseFilter builds a client's line filter from the query string: path
+// (comma-separated globs, matched against the file name, or the whole path
+// when the glob contains "/"), grep (regex on the text without escapes), and
+// where (a field expression). Clients only get complete lines, so a filter
+// cannot leave a stale partial behind.
+func parseFilter(r *http.Request) (func(tailer.Line) bool, error) {
+	query := r.URL.Query()
+	var globs []string
+	for _, glob := range strings.Split(query.Get("path"), ",") {
+		if glob = strings.TrimSpace(glob); glob == "" {
+			continue
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid path glob %q: %w", glob, err)
+		}
+		globs = append(globs, glob)
+	}
+	var grep *regexp.Regexp
+	if value := query.Get("grep"); value != "" {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grep pattern %q: %w", value, err)
+		}
+		grep = re
+	}
+	var where *tailer.Query
+	if value := query.Get("where"); value != "" {
+		q, err := tailer.ParseQuery(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where expression: %w", err)
+		}
+		where = q
+	}
+	return func(line tailer.Line) bool {
+		if line.Partial {
+			return false
+		}
+		if len(globs) > 0 && !matchGlobs(globs, line.Path) {
+			return false
+		}
+		if line.Marker {
+			return true
+		}
+		if grep != nil && !grep.MatchString(tailer.StripANSI(line.Text)) {
+			return false
+		}
+		return where == nil || where.Match(line)
+	}, nil
+}
+
+func matchGlobs(globs []string, name string) bool {
+	for _, glob := range globs {
+		target := path.Base(name)
+		if strings.Contains(glob, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(glob, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) subscribe(match func(tailer.Line) bool) (<-chan tailer.Line, func()) {
+	return s.source.Subscribe(tailer.Filter{Match: match, Buffer: clientBuffer, Overflow: tailer.OverflowDropOldest})
+}
+
+// originAllowed guards against cross-site WebSocket hijacking: browsers
+// send Origin with every WebSocket handshake and CORS does not apply, so any
+// page could otherwise read the stream. Requests without Origin come from
+// non-browser clients.
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range s.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func encodeLine(line tailer.Line) []byte {
+	line.Update = false
+	return bytes.TrimSuffix(output.JSONFormatter{}.Format(line), []byte("\n"))
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	match, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	lines, cancel := s.subscribe(match)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", encodeLine(line)); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	match, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.originAllowed(r) {
+		http.Error(w, fmt.Sprintf("origin %q not allowed", r.Header.Get("Origin")), http.StatusForbidden)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer ws.Close()
+	lines, cancel := s.subscribe(match)
+	defer cancel()
+
+	done := make(chan struct{})
+	go ws.readLoop(done)
+	for {
+		select {
+		case <-done:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				ws.writeFrame(opClose, []byte{0x03, 0xE9})
+				return
+			}
+			if err := ws.writeFrame(opText, encodeLine(line)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"folder-tail/internal/tailer"
+)
+
+type fakeSource struct {
+	files []tailer.FileStat
+	mu    sync.Mutex
+	subs  map[chan tailer.Line]tailer.Filter
+}
+
+func newFakeSource(files ...tailer.FileStat) *fakeSource {
+	return &fakeSource{files: files, subs: make(map[chan tailer.Line]tailer.Filter)}
+}
+
+func (f *fakeSource) Files() []tailer.FileStat { return f.files }
+
+func (f *fakeSource) Subscribe(filter tailer.Filter) (<-chan tailer.Line, func()) {
+	ch := make(chan tailer.Line, 8)
+	f.mu.Lock()
+	f.subs[ch] = filter
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeSource) send(line tailer.Line) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch, filter := range f.subs {
+		if filter.Match(line) {
+			ch <- line
+		}
+	}
+}
+
+func (f *fakeSource) waitSubscribers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		count := len(f.subs)
+		f.mu.Unlock()
+		if count == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d subscribers", n)
+}
+
+func TestEventsStream(t *testing.T) {
+	source := newFakeSource()
+	srv := httptest.NewServer(New(source, nil))
+	defer srv.Close()
+
+	query := url.Values{"path": {"*.log"}, "where": {"level>=warn"}}
+	resp, err := http.Get(srv.URL + "/events?" + query.Encode())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	source.waitSubscribers(t, 1)
+
+	source.send(tailer.Line{Path: "app.log", Text: "INFO hello", Level: tailer.LevelInfo})
+	source.send(tailer.Line{Path: "app.txt", Text: "ERROR other file", Level: tailer.LevelError})
+	source.send(tailer.Line{Path: "app.log", Text: "ERROR boom", Level: tailer.LevelError})
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var got struct {
+			Path  string `json:"path"`
+			Text  string `json:"text"`
+			Level string `json:"level"`
+		}
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatalf("decode %q: %v", data, err)
+		}
+		if got.Path != "app.log" || got.Text != "ERROR boom" || got.Level != "error" {
+			t.Fatalf("unexpected event %+v", got)
+		}
+		return
+	}
+}
+
+func TestInvalidFilters(t *testing.T) {
+	srv := httptest.NewServer(New(newFakeSource(), nil))
+	defer srv.Close()
+
+	for query, want := range map[string]string{
+		"grep=%5B":       `invalid grep pattern "["`,
+		"where=level%3E": `invalid where expression: column 7: expected a value after ">"`,
+		"path=%5B":       `invalid path glob "["`,
+	} {
+		resp, err := http.Get(srv.URL + "/events?" + query)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		body := make([]byte, 512)
+		n, _ := resp.Body.Read(body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body[:n]), want) {
+			t.Fatalf("%s: expected 400 with %q, got %d %q", query, want, resp.StatusCode, body[:n])
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	when := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(New(newFakeSource(tailer.FileStat{Path: "a.log", AbsPath: "/logs/a.log", Lines: 3, LastActivity: when}), nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/files")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	var buf strings.Builder
+	bufio.NewReader(resp.Body).WriteTo(&buf)
+	want := `[{"path":"a.log","abs_path":"/logs/a.log","lines":3,"last_activity":"2026-10-16T12:00:00Z"}]` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected files %q", buf.String())
+	}
+}
+
+func TestWebSocketStream(t *testing.T) {
+	source := newFakeSource()
+	srv := httptest.NewServer(New(source, nil))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	request := "GET /ws?grep=boom HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake %d %v", resp.StatusCode, resp.Header)
+	}
+	source.waitSubscribers(t, 1)
+
+	source.send(tailer.Line{Path: "a.log", Text: "quiet"})
+	source.send(tailer.Line{Path: "a.log", Text: "boom"})
+
+	ws := &wsConn{conn: conn, rw: bufio.NewReadWriter(reader, bufio.NewWriter(conn))}
+	var head [2]byte
+	if _, err := ws.rw.Read(head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if head[0] != 0x80|opText || head[1]&0x80 != 0 {
+		t.Fatalf("expected an unmasked final text frame, got %x", head)
+	}
+	payload := make([]byte, head[1]&0x7F)
+	if _, err := ws.rw.Read(payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	if !strings.Contains(string(payload), `"text":"boom"`) {
+		t.Fatalf("unexpected payload %s", payload)
+	}
+
+	// A masked close frame is echoed back.
+	mask := []byte{1, 2, 3, 4}
+	status := []byte{0x03, 0xE8}
+	frame := []byte{0x80 | opClose, 0x80 | byte(len(status))}
+	frame = append(frame, mask...)
+	for i, b := range status {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("write close: %v", err)
+	}
+	if _, err := ws.rw.Read(head[:]); err != nil || head[0] != 0x80|opClose {
+		t.Fatalf("expected a close frame back, got %x %v", head, err)
+	}
+	source.waitSubscribers(t, 0)
+}
+
+func TestWebSocketOriginCheck(t *testing.T) {
+	srv := httptest.NewServer(New(newFakeSource(), []string{"https://dash.example.com"}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for origin, want := range map[string]int{
+		"":                         http.StatusSwitchingProtocols,
+		"http://" + host:           http.StatusSwitchingProtocols,
+		"https://dash.example.com": http.StatusSwitchingProtocols,
+		"https://evil.example.com": http.StatusForbidden,
+		"http://localhost.evil":    http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%q: %v", origin, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("origin %q: expected %d, got %d", origin, want, resp.StatusCode)
+		}
+	}
+}
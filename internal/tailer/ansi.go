@@ -0,0 +1,159 @@
+package tailer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ANSIMode int
+
+const (
+	// ANSISanitize keeps SGR (color and style) sequences and makes every
+	// other escape sequence and control character visible in caret notation.
+	ANSISanitize ANSIMode = iota
+	ANSIKeep
+	ANSIStrip
+)
+
+func ParseANSIMode(value string) (ANSIMode, error) {
+	switch value {
+	case "", "sanitize":
+		return ANSISanitize, nil
+	case "keep":
+		return ANSIKeep, nil
+	case "strip":
+		return ANSIStrip, nil
+	default:
+		return 0, fmt.Errorf("invalid ansi mode %q (want keep, strip or sanitize)", value)
+	}
+}
+
+func (m ANSIMode) String() string {
+	switch m {
+	case ANSIKeep:
+		return "keep"
+	case ANSIStrip:
+		return "strip"
+	default:
+		return "sanitize"
+	}
+}
+
+// escapeLen returns the length of the escape sequence at the start of s and
+// whether it is an SGR sequence. s must start with ESC.
+func escapeLen(s string) (int, bool) {
+	if len(s) < 2 {
+		return len(s), false
+	}
+	switch s[1] {
+	case '[':
+		// CSI: parameter bytes, intermediate bytes, one final byte.
+		i := 2
+		for i < len(s) && s[i] >= 0x30 && s[i] <= 0x3f {
+			i++
+		}
+		for i < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+			i++
+		}
+		if i < len(s) && s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1, s[i] == 'm'
+		}
+		return i, false
+	case ']', 'P', '_', '^', 'X':
+		// OSC, DCS and friends run to BEL or ST (ESC \).
+		for i := 2; i < len(s); i++ {
+			if s[i] == 0x07 {
+				return i + 1, false
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2, false
+			}
+		}
+		return len(s), false
+	default:
+		return 2, false
+	}
+}
+
+func hasControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 && c != '\t' && c != '\n' || c == 0x7f {
+			return true
+		}
+	}
+	return strings.Contains(s, "\u009b")
+}
+
+// applyANSI rewrites text for the mode. Newlines are kept, since multiline
+// records join their lines with them.
+func applyANSI(mode ANSIMode, text string) string {
+	if mode == ANSIKeep || !hasControl(text) {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == 0x1b:
+			n, sgr := escapeLen(text[i:])
+			if mode == ANSISanitize {
+				if sgr {
+					b.WriteString(text[i : i+n])
+				} else {
+					writeCaret(&b, text[i:i+n])
+				}
+			}
+			i += n
+		case c < 0x20 && c != '\t' && c != '\n' || c == 0x7f:
+			if mode == ANSISanitize {
+				writeCaret(&b, text[i:i+1])
+			}
+			i++
+		case strings.HasPrefix(text[i:], "\u009b"):
+			// A C1 CSI can be read as an escape by some terminals.
+			if mode == ANSISanitize {
+				b.WriteString("<9b>")
+			}
+			i += len("\u009b")
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func writeCaret(b *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0x7f:
+			b.WriteString("^?")
+		case c < 0x20 && c != '\t' && c != '\n':
+			b.WriteByte('^')
+			b.WriteByte(c + '@')
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// StripANSI removes escape sequences, for matching and measuring text that
+// may carry colors.
+func StripANSI(text string) string {
+	if !strings.Contains(text, "\x1b") {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			n, _ := escapeLen(text[i:])
+			i += n
+			continue
+		}
+		b.WriteByte(text[i])
+		i++
+	}
+	return b.String()
+}
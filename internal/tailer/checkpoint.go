@@ -0,0 +1,181 @@
+package tailer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	checkpointVersion         = 1
+	fingerprintSize           = 1024
+	defaultCheckpointInterval = 5 * time.Second
+	// checkpointRetention is how long an entry for a file that is no longer
+	// tailed is kept, so another run sharing the state file can still resume.
+	checkpointRetention = 7 * 24 * time.Hour
+)
+
+type checkpoint struct {
+	Dev            uint64    `json:"dev,omitempty"`
+	Ino            uint64    `json:"ino,omitempty"`
+	Fingerprint    string    `json:"fingerprint"`
+	FingerprintLen int64     `json:"fingerprint_len"`
+	Offset         int64     `json:"offset"`
+	Updated        time.Time `json:"updated"`
+}
+
+type checkpointFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]checkpoint `json:"files"`
+}
+
+func loadCheckpoints(path string) (map[string]checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return make(map[string]checkpoint), nil
+		}
+		return nil, err
+	}
+	var stored checkpointFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %q: %w", path, err)
+	}
+	if stored.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint file version %d in %q", stored.Version, path)
+	}
+	if stored.Files == nil {
+		stored.Files = make(map[string]checkpoint)
+	}
+	return stored.Files, nil
+}
+
+func writeCheckpoints(path string, files map[string]checkpoint) error {
+	data, err := json.MarshalIndent(checkpointFile{Version: checkpointVersion, Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func fingerprint(file io.ReaderAt, size int64) (string, int64, error) {
+	n := size
+	if n > fingerprintSize {
+		n = fingerprintSize
+	}
+	buf := make([]byte, n)
+	if _, err := file.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), n, nil
+}
+
+func (t *Tailer) saveCheckpoints() error {
+	if t.cfg.CheckpointPath == "" {
+		return nil
+	}
+	t.mu.Lock()
+	paths := make(map[string]*fileState, len(t.states))
+	for path, state := range t.states {
+		paths[path] = state
+	}
+	t.mu.Unlock()
+
+	now := time.Now()
+	for path, state := range paths {
+		cp, err := t.checkpointFor(path, state)
+		if err != nil {
+			continue
+		}
+		cp.Updated = now
+		t.saved[path] = cp
+	}
+	for path, cp := range t.saved {
+		if _, ok := paths[path]; !ok && now.Sub(cp.Updated) > checkpointRetention {
+			delete(t.saved, path)
+		}
+	}
+	return writeCheckpoints(t.cfg.CheckpointPath, t.saved)
+}
+
+func (t *Tailer) checkpointFor(path string, state *fileState) (checkpoint, error) {
+	file, owned, err := t.openFile(path, state)
+	if err != nil {
+		return checkpoint{}, err
+	}
+	if owned {
+		defer file.Close()
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return checkpoint{}, err
+	}
+	sum, n, err := fingerprint(file, info.Size())
+	if err != nil {
+		return checkpoint{}, err
+	}
+	offset := state.lineStart
+	if state.record != nil {
+		// Re-read a record still being assembled rather than resuming mid-record.
+		offset = state.record.line.Offset
+	}
+	cp := checkpoint{Fingerprint: sum, FingerprintLen: n, Offset: offset}
+	if state.hasID {
+		cp.Dev = state.id.dev
+		cp.Ino = state.id.ino
+	}
+	return cp, nil
+}
+
+func (t *Tailer) resumeOffset(path string, state *fileState) (int64, bool) {
+	cp, ok := t.saved[path]
+	if !ok {
+		return 0, false
+	}
+	file, owned, err := t.openFile(path, state)
+	if err != nil {
+		return 0, false
+	}
+	if owned {
+		defer file.Close()
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, false
+	}
+	if state.hasID && (cp.Dev != 0 || cp.Ino != 0) && (cp.Dev != state.id.dev || cp.Ino != state.id.ino) {
+		return 0, false
+	}
+	if cp.Offset < 0 || cp.Offset > info.Size() || cp.FingerprintLen > info.Size() {
+		return 0, false
+	}
+	sum, _, err := fingerprint(file, cp.FingerprintLen)
+	if err != nil || sum != cp.Fingerprint {
+		return 0, false
+	}
+	return cp.Offset, true
+}
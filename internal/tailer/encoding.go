@@ -0,0 +1,370 @@
+package tailer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const encodingSampleSize = 4096
+
+// textEncoding describes how a file's bytes map to UTF-8. A nil
+// *textEncoding is UTF-8 and passes bytes through untouched.
+type textEncoding struct {
+	name string
+	enc  encoding.Encoding
+	// width is the size of a newline: 2 for UTF-16, 1 for everything else.
+	// Multi-byte encodings other than UTF-16 never use 0x0A inside a
+	// character, so their lines can be split on raw bytes.
+	width     int
+	bigEndian bool
+}
+
+var (
+	utf16LE = &textEncoding{name: "utf-16le", enc: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), width: 2}
+	utf16BE = &textEncoding{name: "utf-16be", enc: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), width: 2, bigEndian: true}
+)
+
+// cjkCandidates are tried, in order, on samples that are not valid UTF-8.
+// Each is scored by how many decoded runes fall in the scripts it is
+// normally used for.
+var cjkCandidates = []struct {
+	enc    *textEncoding
+	script func(r rune) bool
+}{
+	{&textEncoding{name: "gb18030", enc: simplifiedchinese.GB18030, width: 1}, isHan},
+	{&textEncoding{name: "shift_jis", enc: japanese.ShiftJIS, width: 1}, isJapanese},
+	{&textEncoding{name: "euc-jp", enc: japanese.EUCJP, width: 1}, isJapanese},
+	{&textEncoding{name: "euc-kr", enc: korean.EUCKR, width: 1}, isHangul},
+	{&textEncoding{name: "big5", enc: traditionalchinese.Big5, width: 1}, isHan},
+}
+
+func isHan(r rune) bool      { return r >= 0x4e00 && r <= 0x9fff }
+func isHangul(r rune) bool   { return r >= 0xac00 && r <= 0xd7af }
+func isJapanese(r rune) bool { return r >= 0x3040 && r <= 0x30ff || isHan(r) }
+
+type encodingRule struct {
+	pattern *pattern
+	enc     *textEncoding
+}
+
+// lookupEncoding accepts WHATWG encoding names and labels ("latin1",
+// "shift_jis", "gbk", "utf-16le"). "utf-8" and "auto" mean no transcoding
+// and detection respectively, and both return nil.
+func lookupEncoding(name string) (*textEncoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto", "utf-8", "utf8":
+		return nil, nil
+	case "utf-16", "utf-16le":
+		return utf16LE, nil
+	case "utf-16be":
+		return utf16BE, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	canonical, _ := htmlindex.Name(enc)
+	return &textEncoding{name: canonical, enc: enc, width: 1}, nil
+}
+
+// parseEncodingRules reads "-encoding" values: a bare name applies to every
+// file, "pattern=name" to files matching the include-style pattern. Later
+// rules win.
+func parseEncodingRules(values []string, forceRegex bool) ([]encodingRule, error) {
+	var rules []encodingRule
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		var rule encodingRule
+		name := value
+		if raw, n, ok := strings.Cut(value, "="); ok {
+			patterns, err := compilePatterns([]string{raw}, forceRegex)
+			if err != nil {
+				return nil, err
+			}
+			if len(patterns) > 0 {
+				rule.pattern = &patterns[0]
+			}
+			name = n
+		}
+		enc, err := lookupEncoding(name)
+		if err != nil {
+			return nil, err
+		}
+		if enc == nil && !isAutoEncoding(name) {
+			enc = &textEncoding{name: "utf-8", width: 1}
+		}
+		rule.enc = enc
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func isAutoEncoding(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return name == "" || name == "auto"
+}
+
+// encodingFor applies the last matching -encoding rule, falling back to
+// detection from the start of the file.
+func (t *Tailer) encodingFor(path string) *textEncoding {
+	_, rel := t.sourceFor(path)
+	name := filepath.Base(path)
+	for i := len(t.encodings) - 1; i >= 0; i-- {
+		rule := t.encodings[i]
+		if rule.pattern != nil {
+			if ok, err := matchCompiledPattern(*rule.pattern, name, rel); err != nil || !ok {
+				continue
+			}
+		}
+		if rule.enc != nil {
+			if rule.enc.enc == nil {
+				return nil
+			}
+			return rule.enc
+		}
+		break
+	}
+	sample, err := readSample(path, encodingSampleSize)
+	if err != nil {
+		return nil
+	}
+	return detectEncoding(sample)
+}
+
+// detectEncoding sniffs a byte order mark, then UTF-16 by the position of
+// zero bytes, then UTF-8 validity, then the CJK multi-byte encodings. Samples
+// that fit none of them are read as Windows-1252, a superset of Latin-1.
+func detectEncoding(sample []byte) *textEncoding {
+	if bytes.HasPrefix(sample, []byte{0xef, 0xbb, 0xbf}) {
+		return nil
+	}
+	if enc := detectUTF16(sample); enc != nil {
+		return enc
+	}
+	if validUTF8Prefix(sample) {
+		return nil
+	}
+	if enc := detectCJK(sample); enc != nil {
+		return enc
+	}
+	enc, _ := lookupEncoding("windows-1252")
+	return enc
+}
+
+// detectCJK picks the CJK encoding whose decode is clean and mostly made of
+// its scripts. Latin-1 accents often pair with the next letter into a valid
+// CJK character ("ño" is a GB18030 Han character), so samples whose high
+// bytes mostly stand alone are left to Windows-1252.
+func detectCJK(sample []byte) *textEncoding {
+	if looksSingleByte(sample) {
+		return nil
+	}
+	// 0x0A never occurs inside these encodings' characters, so cutting at the
+	// last newline drops a character split at the end of the sample.
+	if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+		sample = sample[:i+1]
+	}
+	var best *textEncoding
+	bestScore := 0
+	for _, candidate := range cjkCandidates {
+		decoded, err := candidate.enc.enc.NewDecoder().Bytes(sample)
+		if err != nil || bytes.Contains(decoded, []byte("\uFFFD")) {
+			continue
+		}
+		score, nonASCII := 0, 0
+		for _, r := range string(decoded) {
+			if r >= utf8.RuneSelf {
+				nonASCII++
+			}
+			if candidate.script(r) {
+				score++
+			}
+		}
+		if score*2 < nonASCII {
+			continue
+		}
+		if score > bestScore {
+			best, bestScore = candidate.enc, score
+		}
+	}
+	return best
+}
+
+// looksSingleByte reports whether most bytes above 0x7F sit alone between
+// ASCII bytes, as accented letters do; CJK text uses them in runs.
+func looksSingleByte(sample []byte) bool {
+	var high, alone int
+	for i, b := range sample {
+		if b < utf8.RuneSelf {
+			continue
+		}
+		high++
+		if (i == 0 || sample[i-1] < utf8.RuneSelf) && (i+1 == len(sample) || sample[i+1] < utf8.RuneSelf) {
+			alone++
+		}
+	}
+	return alone*2 > high
+}
+
+// detectUTF16 recognizes UTF-16 by its byte order mark, or without one when
+// the text is mostly ASCII and every other byte is zero.
+func detectUTF16(sample []byte) *textEncoding {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		return utf16LE
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		return utf16BE
+	}
+	pairs := len(sample) / 2
+	if pairs < 2 {
+		return nil
+	}
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenZeros++
+		}
+		if sample[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros*10 >= pairs*4 && evenZeros*10 < pairs:
+		return utf16LE
+	case evenZeros*10 >= pairs*4 && oddZeros*10 < pairs:
+		return utf16BE
+	}
+	return nil
+}
+
+// validUTF8Prefix ignores a character cut off at the end of the sample.
+func validUTF8Prefix(sample []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(sample) > 0; i++ {
+		if utf8.Valid(sample) {
+			return true
+		}
+		sample = sample[:len(sample)-1]
+	}
+	return utf8.Valid(sample)
+}
+
+func (e *textEncoding) String() string {
+	if e == nil {
+		return "utf-8"
+	}
+	return e.name
+}
+
+func (e *textEncoding) newlineWidth() int {
+	if e == nil {
+		return 1
+	}
+	return e.width
+}
+
+// indexNewline finds the first newline in data, which for UTF-16 must start
+// at an even index (reads are kept aligned to two bytes).
+func (e *textEncoding) indexNewline(data []byte) int {
+	if e.newlineWidth() == 1 {
+		return bytes.IndexByte(data, '\n')
+	}
+	for i := 0; i+1 < len(data); i += 2 {
+		if e.bigEndian && data[i] == 0 && data[i+1] == '\n' || !e.bigEndian && data[i] == '\n' && data[i+1] == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *textEncoding) countNewlines(data []byte) int {
+	count := 0
+	for {
+		idx := e.indexNewline(data)
+		if idx < 0 {
+			return count
+		}
+		count++
+		data = data[idx+e.newlineWidth():]
+	}
+}
+
+func (e *textEncoding) trimCR(data []byte) []byte {
+	if e.newlineWidth() == 1 {
+		return trimTrailingCR(data)
+	}
+	cr := []byte{'\r', 0}
+	if e.bigEndian {
+		cr = []byte{0, '\r'}
+	}
+	return bytes.TrimSuffix(data, cr)
+}
+
+// splitLines is splitLines for any encoding: complete lines are decoded, the
+// trailing partial line is kept as raw bytes.
+func (e *textEncoding) splitLines(data []byte) ([]string, []byte) {
+	if e.newlineWidth() == 1 {
+		lines, partial := splitLines(data)
+		if e != nil {
+			for i, line := range lines {
+				lines[i] = e.decode([]byte(line))
+			}
+		}
+		return lines, partial
+	}
+	var lines []string
+	for {
+		idx := e.indexNewline(data)
+		if idx < 0 {
+			break
+		}
+		lines = append(lines, e.decode(e.trimCR(data[:idx])))
+		data = data[idx+e.width:]
+	}
+	if len(data) == 0 {
+		return lines, nil
+	}
+	return lines, e.trimCR(append([]byte(nil), data...))
+}
+
+func (e *textEncoding) decode(data []byte) string {
+	if e == nil || e.enc == nil {
+		return string(data)
+	}
+	decoded, err := e.enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(decoded)
+}
+
+// text turns the raw bytes of one line (without its newline) into display
+// text: decoded, without a trailing CR, without a byte order mark at the
+// start of the file, and truncated to max bytes of UTF-8.
+func (e *textEncoding) text(data []byte, max int, fileStart bool) (string, bool) {
+	data = e.trimCR(data)
+	if e == nil || e.enc == nil {
+		if fileStart {
+			data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+		}
+		return truncateLineBytes(data, max)
+	}
+	decoded := e.decode(data)
+	if fileStart {
+		decoded = strings.TrimPrefix(decoded, "\uFEFF")
+	}
+	return truncateLineBytes([]byte(decoded), max)
+}
@@ -0,0 +1,111 @@
+package tailer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+const errorHistorySize = 256
+
+type Op string
+
+const (
+	OpWatch      Op = "watch"
+	OpStat       Op = "stat"
+	OpRead       Op = "read"
+	OpWalk       Op = "walk"
+	OpTextDetect Op = "text-detect"
+	OpCheckpoint Op = "checkpoint"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+type Error struct {
+	Path     string
+	Op       Op
+	Time     time.Time
+	Severity Severity
+	Err      error
+}
+
+func (e *Error) Error() string {
+	var pathErr *fs.PathError
+	if e.Path == "" || errors.As(e.Err, &pathErr) && pathErr.Path == e.Path {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError classifies err. Stat failures surfaced while reading are reported as
+// OpStat. Files that vanish or are unreadable are routine while tailing a live
+// tree, so they are warnings, as are polling fallbacks and skipped symlink loops.
+func newError(op Op, path string, err error) *Error {
+	var pathErr *fs.PathError
+	if op == OpRead && errors.As(err, &pathErr) && (pathErr.Op == "stat" || pathErr.Op == "lstat") {
+		op = OpStat
+	}
+	severity := SeverityError
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, errPollFallback) || errors.Is(err, errSymlinkLoop) {
+		severity = SeverityWarning
+	}
+	return &Error{Path: path, Op: op, Time: time.Now(), Severity: severity, Err: err}
+}
+
+type errorHistory struct {
+	entries []*Error
+	next    int
+	total   int64
+}
+
+func (h *errorHistory) add(err *Error) {
+	h.total++
+	if len(h.entries) < errorHistorySize {
+		h.entries = append(h.entries, err)
+		return
+	}
+	h.entries[h.next] = err
+	h.next = (h.next + 1) % errorHistorySize
+}
+
+func (h *errorHistory) list() []*Error {
+	out := make([]*Error, 0, len(h.entries))
+	out = append(out, h.entries[h.next:]...)
+	return append(out, h.entries[:h.next]...)
+}
+
+// ErrorHistory returns the most recent errors, oldest first, including ones
+// that did not fit in the Errors channel.
+func (t *Tailer) ErrorHistory() []*Error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.errHistory.list()
+}
+
+func (t *Tailer) sendErr(op Op, path string, err error) {
+	e := newError(op, path, err)
+	t.mu.Lock()
+	t.errHistory.add(e)
+	t.mu.Unlock()
+	select {
+	case t.errs <- e:
+	default:
+	}
+}
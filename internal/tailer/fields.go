@@ -0,0 +1,232 @@
+package tailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type FieldFormat int
+
+const (
+	FieldsAuto FieldFormat = iota
+	FieldsJSON
+	FieldsLogfmt
+	FieldsOff
+)
+
+func ParseFieldFormat(value string) (FieldFormat, error) {
+	switch value {
+	case "", "auto":
+		return FieldsAuto, nil
+	case "json":
+		return FieldsJSON, nil
+	case "logfmt":
+		return FieldsLogfmt, nil
+	case "off":
+		return FieldsOff, nil
+	default:
+		return 0, fmt.Errorf("invalid field format %q (want auto, json, logfmt or off)", value)
+	}
+}
+
+func (f FieldFormat) String() string {
+	switch f {
+	case FieldsJSON:
+		return "json"
+	case FieldsLogfmt:
+		return "logfmt"
+	case FieldsOff:
+		return "off"
+	default:
+		return "auto"
+	}
+}
+
+// Field is one key of a structured line. JSON values keep their decoded
+// type (string, json.Number, bool, nil, map[string]any or []any); logfmt
+// values are always strings.
+type Field struct {
+	Key   string
+	Value any
+}
+
+type Fields []Field
+
+// Get returns the value of key, looking into nested JSON objects for dotted
+// keys ("http.status") that are not a top-level key themselves.
+func (f Fields) Get(key string) (any, bool) {
+	for _, field := range f {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	parts := strings.Split(key, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+	value, ok := f.Get(parts[0])
+	for _, part := range parts[1:] {
+		object, isObject := value.(map[string]any)
+		if !ok || !isObject {
+			return nil, false
+		}
+		value, ok = object[part]
+	}
+	return value, ok
+}
+
+// FormatValue renders a field value the way it appeared in the line:
+// strings unquoted, everything else as compact JSON.
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func parseFields(format FieldFormat, text string) Fields {
+	switch format {
+	case FieldsOff:
+		return nil
+	case FieldsJSON:
+		return parseJSONFields(text)
+	case FieldsLogfmt:
+		return parseLogfmt(text)
+	}
+	if fields := parseJSONFields(text); fields != nil {
+		return fields
+	}
+	return parseLogfmt(text)
+}
+
+// parseJSONFields decodes a line holding a single JSON object, keeping the
+// order of its top-level keys.
+func parseJSONFields(text string) Fields {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return nil
+	}
+	var fields Fields
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil
+		}
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	if _, err := dec.Token(); err != nil || dec.More() {
+		return nil
+	}
+	if fields == nil {
+		fields = Fields{}
+	}
+	return fields
+}
+
+// parseLogfmt accepts lines made only of key=value pairs (values may be
+// quoted), with at least two pairs so that prose containing a stray "="
+// is left alone.
+func parseLogfmt(text string) Fields {
+	var fields Fields
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		eq := strings.IndexAny(rest, "= \t\"")
+		if eq <= 0 || rest[eq] != '=' {
+			return nil
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := closingQuote(rest)
+			if end < 0 {
+				return nil
+			}
+			quoted := rest[:end+1]
+			unquoted, err := strconv.Unquote(quoted)
+			if err != nil {
+				unquoted = quoted[1 : len(quoted)-1]
+			}
+			value = unquoted
+			rest = rest[end+1:]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				return nil
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value = rest[:end]
+			if strings.Contains(value, `"`) {
+				return nil
+			}
+			rest = rest[end:]
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	if len(fields) < 2 {
+		return nil
+	}
+	return fields
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// PrettyFields renders fields one per line as "key: value", with nested
+// JSON values indented below their key.
+func PrettyFields(fields Fields) string {
+	var builder strings.Builder
+	for i, field := range fields {
+		if i > 0 {
+			builder.WriteByte('\n')
+		}
+		builder.WriteString(field.Key)
+		builder.WriteString(": ")
+		switch field.Value.(type) {
+		case map[string]any, []any:
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, []byte(FormatValue(field.Value)), "", "  "); err == nil {
+				builder.WriteString(buf.String())
+				continue
+			}
+		}
+		builder.WriteString(FormatValue(field.Value))
+	}
+	return builder.String()
+}
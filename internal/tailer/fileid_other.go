@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tailer
+
+import "os"
+
+func fileIdentity(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,186 @@
+package tailer
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type lineFilter struct {
+	includes []*regexp.Regexp
+	excludes []*regexp.Regexp
+	before   int
+	after    int
+}
+
+func compileLineFilter(cfg Config) (*lineFilter, error) {
+	if len(cfg.Grep) == 0 && len(cfg.GrepExclude) == 0 {
+		return nil, nil
+	}
+	includes, err := compileLinePatterns(cfg.Grep, cfg.GrepFixed, cfg.GrepIgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compileLinePatterns(cfg.GrepExclude, cfg.GrepFixed, cfg.GrepIgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+	return &lineFilter{
+		includes: includes,
+		excludes: excludes,
+		before:   max(cfg.GrepBefore, 0),
+		after:    max(cfg.GrepAfter, 0),
+	}, nil
+}
+
+func compileLinePatterns(values []string, fixed, ignoreCase bool) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(values))
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		expr := value
+		if fixed {
+			expr = regexp.QuoteMeta(value)
+		}
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line pattern %q: %w", value, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+func (f *lineFilter) match(text string) bool {
+	if len(f.includes) > 0 {
+		matched := false
+		for _, re := range f.includes {
+			if re.MatchString(text) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range f.excludes {
+		if re.MatchString(text) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *lineFilter) hasContext() bool {
+	return f.before > 0 || f.after > 0
+}
+
+type grepState struct {
+	before  []Line
+	after   int
+	skipped bool
+	emitted bool
+}
+
+// emit assembles multiline records and applies the content filter before
+// handing a line to deliver. Partial lines are held back while grouping or
+// filtering, since a line can only be judged once it is complete.
+func (t *Tailer) emit(state *fileState, path string, line Line) {
+	line.AbsPath = path
+	line.Time = time.Now()
+	if !line.Partial && !line.Marker {
+		t.mu.Lock()
+		state.lineCount++
+		state.lastActivity = line.Time
+		t.mu.Unlock()
+	}
+	if t.multiline != nil {
+		if line.Marker {
+			t.flushRecord(state)
+		} else {
+			if line.Partial {
+				return
+			}
+			line.Update = false
+			record, ok := t.assemble(state, line)
+			if !ok {
+				return
+			}
+			line = record
+		}
+	}
+	t.filterLine(state, path, line)
+}
+
+// filterLine runs a complete line or record through -level, -where,
+// ordering and -grep.
+func (t *Tailer) filterLine(state *fileState, path string, line Line) {
+	if !t.classify(state, &line) {
+		return
+	}
+	if (t.cfg.MinLevel != LevelUnknown || t.where != nil) && !line.Marker {
+		// A partial line may still gain or lose its level and fields, so
+		// only complete lines are shown.
+		if line.Partial {
+			return
+		}
+		line.Update = false
+		if t.where != nil && !t.where.Match(line) {
+			return
+		}
+	}
+	if t.reorder != nil {
+		// Buffered lines cannot be updated in place, so only complete lines
+		// are reordered.
+		if line.Partial {
+			return
+		}
+		line.Update = false
+	}
+	f := t.grep
+	if f == nil || line.Marker {
+		t.deliver(state, line)
+		return
+	}
+	if line.Partial {
+		return
+	}
+	line.Update = false
+	g := &state.grep
+
+	if f.match(StripANSI(line.Text)) {
+		if g.skipped && g.emitted && f.hasContext() {
+			t.deliver(state, Line{Path: line.Path, AbsPath: path, Text: "--", Time: line.Time, Marker: true})
+		}
+		for _, prev := range g.before {
+			t.deliver(state, prev)
+		}
+		g.before = g.before[:0]
+		t.deliver(state, line)
+		g.after = f.after
+		g.skipped = false
+		g.emitted = true
+		return
+	}
+
+	if g.after > 0 {
+		g.after--
+		t.deliver(state, line)
+		return
+	}
+	if f.before == 0 {
+		g.skipped = true
+		return
+	}
+	if len(g.before) == f.before {
+		copy(g.before, g.before[1:])
+		g.before = g.before[:len(g.before)-1]
+		g.skipped = true
+	}
+	g.before = append(g.before, line)
+}
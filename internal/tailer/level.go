@@ -0,0 +1,191 @@
+package tailer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Level int
+
+const (
+	LevelUnknown Level = iota
+	LevelTrace
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = map[string]Level{
+	"trace":    LevelTrace,
+	"debug":    LevelDebug,
+	"dbg":      LevelDebug,
+	"verbose":  LevelDebug,
+	"info":     LevelInfo,
+	"inf":      LevelInfo,
+	"notice":   LevelInfo,
+	"warn":     LevelWarn,
+	"warning":  LevelWarn,
+	"wrn":      LevelWarn,
+	"error":    LevelError,
+	"err":      LevelError,
+	"fatal":    LevelFatal,
+	"crit":     LevelFatal,
+	"critical": LevelFatal,
+	"panic":    LevelFatal,
+	"alert":    LevelFatal,
+	"emerg":    LevelFatal,
+}
+
+func ParseLevel(value string) (Level, error) {
+	if value == "" {
+		return LevelUnknown, nil
+	}
+	if level, ok := levelNames[strings.ToLower(value)]; ok {
+		return level, nil
+	}
+	return LevelUnknown, fmt.Errorf("invalid level %q (want trace, debug, info, warn, error or fatal)", value)
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	default:
+		return ""
+	}
+}
+
+// levelSearch bounds how far into a line a level keyword is looked for.
+const levelSearch = 160
+
+var (
+	fieldLevelRe   = regexp.MustCompile(`(?i)"?\b(?:level|lvl|severity|loglevel)"?\s*[:=]\s*"?([a-z]+|\d+)\b`)
+	klogLevelRe    = regexp.MustCompile(`^([IWEF])\d{4} `)
+	syslogLevelRe  = regexp.MustCompile(`^<(\d{1,3})>`)
+	keywordLevelRe = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|FATAL|CRIT|CRITICAL|PANIC|EMERG|ALERT)\b|\[(?i:(trace|debug|info|notice|warn|warning|error|err|fatal|crit|critical|panic|emerg|alert))\]`)
+)
+
+// detectLevel looks for, in order: a level field (JSON "level":"warn",
+// logfmt level=warn, bunyan/pino numeric levels), a klog prefix (E1016), a
+// syslog priority (<11>), and an upper-case or bracketed level keyword near
+// the start of the line.
+func detectLevel(text string) Level {
+	if len(text) > levelSearch {
+		text = text[:levelSearch]
+	}
+	if m := fieldLevelRe.FindStringSubmatch(text); m != nil {
+		level := levelNames[strings.ToLower(m[1])]
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			level = numericLevel(n)
+		}
+		if level != LevelUnknown {
+			return level
+		}
+	}
+	if m := klogLevelRe.FindStringSubmatch(text); m != nil {
+		return map[string]Level{"I": LevelInfo, "W": LevelWarn, "E": LevelError, "F": LevelFatal}[m[1]]
+	}
+	if m := syslogLevelRe.FindStringSubmatch(text); m != nil {
+		if pri, err := strconv.Atoi(m[1]); err == nil && pri < 192 {
+			return syslogLevel(pri % 8)
+		}
+	}
+	if m := keywordLevelRe.FindStringSubmatch(text); m != nil {
+		word := m[1]
+		if word == "" {
+			word = m[2]
+		}
+		return levelNames[strings.ToLower(word)]
+	}
+	return LevelUnknown
+}
+
+// numericLevel maps bunyan/pino levels (10 trace .. 60 fatal).
+func numericLevel(n int) Level {
+	switch {
+	case n >= 60:
+		return LevelFatal
+	case n >= 50:
+		return LevelError
+	case n >= 40:
+		return LevelWarn
+	case n >= 30:
+		return LevelInfo
+	case n >= 20:
+		return LevelDebug
+	case n >= 10:
+		return LevelTrace
+	default:
+		return LevelUnknown
+	}
+}
+
+func syslogLevel(severity int) Level {
+	switch {
+	case severity <= 2:
+		return LevelFatal
+	case severity == 3:
+		return LevelError
+	case severity == 4:
+		return LevelWarn
+	case severity <= 6:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
+
+var levelFieldKeys = []string{"level", "lvl", "severity", "loglevel"}
+
+func fieldLevel(line *Line) Level {
+	for _, key := range levelFieldKeys {
+		value, ok := line.Fields.Get(key)
+		if !ok {
+			continue
+		}
+		text := FormatValue(value)
+		if n, err := strconv.Atoi(text); err == nil {
+			return numericLevel(n)
+		}
+		return levelNames[strings.ToLower(text)]
+	}
+	return LevelUnknown
+}
+
+// classify parses the line's fields and sets its level, inheriting the
+// file's previous level for lines without one (stack traces, wrapped
+// messages), and reports whether the line passes -level. Lines with no level
+// at all always pass.
+func (t *Tailer) classify(state *fileState, line *Line) bool {
+	if line.Marker {
+		return true
+	}
+	plain := StripANSI(line.Text)
+	if !line.Partial {
+		line.Fields = parseFields(t.cfg.Fields, plain)
+	}
+	line.Level = fieldLevel(line)
+	if line.Level == LevelUnknown {
+		line.Level = detectLevel(plain)
+	}
+	if line.Level == LevelUnknown {
+		line.Level = state.lastLevel
+	} else if !line.Partial {
+		state.lastLevel = line.Level
+	}
+	return line.Level == LevelUnknown || line.Level >= t.cfg.MinLevel
+}
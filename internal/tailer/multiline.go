@@ -0,0 +1,100 @@
+package tailer
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultMultilineTimeout = 500 * time.Millisecond
+	maxRecordLines          = 1000
+)
+
+// multiline groups physical lines into records. A line starts a new record
+// when it matches start, or, in indent mode, when it does not begin with
+// whitespace; everything else is appended to the record being built.
+type multiline struct {
+	start   *regexp.Regexp
+	indent  bool
+	timeout time.Duration
+}
+
+type pendingRecord struct {
+	line  Line
+	lines int
+	last  time.Time
+}
+
+func compileMultiline(cfg Config) (*multiline, error) {
+	if cfg.MultilineStart == "" && !cfg.MultilineIndent {
+		return nil, nil
+	}
+	m := &multiline{indent: cfg.MultilineIndent, timeout: cfg.MultilineTimeout}
+	if m.timeout <= 0 {
+		m.timeout = defaultMultilineTimeout
+	}
+	if cfg.MultilineStart != "" {
+		re, err := regexp.Compile(cfg.MultilineStart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline start pattern %q: %w", cfg.MultilineStart, err)
+		}
+		m.start = re
+	}
+	return m, nil
+}
+
+func (m *multiline) startsRecord(text string) bool {
+	text = StripANSI(text)
+	if m.start != nil {
+		return m.start.MatchString(text)
+	}
+	return text == "" || !unicode.IsSpace(rune(text[0]))
+}
+
+// assemble adds a complete line to the file's pending record. It returns the
+// record that the line closed, if any.
+func (t *Tailer) assemble(state *fileState, line Line) (Line, bool) {
+	rec := state.record
+	if rec != nil && !t.multiline.startsRecord(line.Text) && rec.lines < maxRecordLines {
+		rec.line.Text += "\n" + line.Text
+		rec.lines++
+		rec.last = line.Time
+		return Line{}, false
+	}
+	state.record = &pendingRecord{line: line, lines: 1, last: line.Time}
+	if rec == nil {
+		return Line{}, false
+	}
+	return rec.line, true
+}
+
+func (t *Tailer) flushRecord(state *fileState) {
+	rec := state.record
+	if rec == nil {
+		return
+	}
+	state.record = nil
+	t.filterLine(state, rec.line.AbsPath, rec.line)
+}
+
+// flushRecords emits records that saw no new line within the timeout, or all
+// of them when all is set.
+func (t *Tailer) flushRecords(all bool) {
+	if t.multiline == nil {
+		return
+	}
+	cutoff := time.Now().Add(-t.multiline.timeout)
+	t.mu.Lock()
+	var ready []*fileState
+	for _, state := range t.states {
+		if state.record != nil && (all || state.record.last.Before(cutoff)) {
+			ready = append(ready, state)
+		}
+	}
+	t.mu.Unlock()
+	for _, state := range ready {
+		t.flushRecord(state)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package tailer
+
+import "syscall"
+
+// Filesystem magic numbers from statfs(2) where inotify misses changes made
+// by other hosts. Statfs_t.Type is int32 on some 32-bit platforms, so the
+// magic is compared as uint32.
+var networkFSTypes = map[uint32]bool{
+	0x6969:     true, // NFS
+	0x517b:     true, // SMB
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+	0x65735546: true, // FUSE
+	0x5346414f: true, // AFS
+	0x01021997: true, // 9P
+	0x47504653: true, // GPFS
+	0x00c36400: true, // CephFS
+	0x0bd00bd0: true, // Lustre
+}
+
+func isNetworkFS(path string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return false
+	}
+	return networkFSTypes[uint32(stat.Type)]
+}
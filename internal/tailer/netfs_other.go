@@ -0,0 +1,7 @@
+//go:build !linux
+
+package tailer
+
+func isNetworkFS(path string) bool {
+	return false
+}
@@ -0,0 +1,133 @@
+package tailer
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+)
+
+const defaultReorderWindow = time.Second
+
+type Order int
+
+const (
+	OrderArrival Order = iota
+	OrderTime
+)
+
+func ParseOrder(value string) (Order, error) {
+	switch value {
+	case "", "arrival":
+		return OrderArrival, nil
+	case "time":
+		return OrderTime, nil
+	default:
+		return 0, fmt.Errorf("invalid order %q (want arrival or time)", value)
+	}
+}
+
+func (o Order) String() string {
+	if o == OrderTime {
+		return "time"
+	}
+	return "arrival"
+}
+
+type reorderEntry struct {
+	line    Line
+	key     time.Time
+	seq     uint64
+	arrived time.Time
+}
+
+type reorderHeap []reorderEntry
+
+func (h reorderHeap) Len() int { return len(h) }
+
+func (h reorderHeap) Less(i, j int) bool {
+	if !h[i].key.Equal(h[j].key) {
+		return h[i].key.Before(h[j].key)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h reorderHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *reorderHeap) Push(x any) { *h = append(*h, x.(reorderEntry)) }
+
+func (h *reorderHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// reorderBuffer holds lines for a short window so lines from different files
+// can be released in timestamp order. It is owned by the event loop.
+type reorderBuffer struct {
+	entries reorderHeap
+	seq     uint64
+	window  time.Duration
+	parser  timestampParser
+}
+
+func newReorderBuffer(cfg Config) *reorderBuffer {
+	if cfg.Order != OrderTime {
+		return nil
+	}
+	window := cfg.ReorderWindow
+	if window <= 0 {
+		window = defaultReorderWindow
+	}
+	return &reorderBuffer{window: window, parser: timestampParser{layout: cfg.TimeLayout}}
+}
+
+// deliver sends the line on, or buffers it when ordering by time. Lines
+// without a timestamp of their own (stack traces, markers) sort with the
+// previous line of their file; files without any timestamps sort by arrival.
+func (t *Tailer) deliver(state *fileState, line Line) {
+	r := t.reorder
+	if r == nil {
+		t.sendLine(line)
+		return
+	}
+	key := line.Time
+	if ts, ok := r.parser.parse(StripANSI(line.Text)); ok && !line.Marker {
+		line.Timestamp = ts
+		state.lastStamp = ts
+		key = ts
+	} else if !state.lastStamp.IsZero() {
+		line.Timestamp = state.lastStamp
+		key = state.lastStamp
+	}
+	r.seq++
+	heap.Push(&r.entries, reorderEntry{line: line, key: key, seq: r.seq, arrived: line.Time})
+}
+
+// flushReorder releases, in timestamp order, every line that has waited for
+// the full window along with any newer line that sorts before one of them.
+// With all set (initial backlog, shutdown) everything is released.
+func (t *Tailer) flushReorder(all bool) {
+	r := t.reorder
+	if r == nil || len(r.entries) == 0 {
+		return
+	}
+	var limit time.Time
+	if !all {
+		cutoff := time.Now().Add(-r.window)
+		found := false
+		for _, entry := range r.entries {
+			if !entry.arrived.After(cutoff) && (!found || entry.key.After(limit)) {
+				limit = entry.key
+				found = true
+			}
+		}
+		if !found {
+			return
+		}
+	}
+	for len(r.entries) > 0 && (all || !r.entries[0].key.After(limit)) {
+		entry := heap.Pop(&r.entries).(reorderEntry)
+		t.sendLine(entry.line)
+	}
+}
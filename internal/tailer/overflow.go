@@ -0,0 +1,162 @@
+package tailer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const dropFlushInterval = time.Second
+
+type OverflowPolicy int
+
+const (
+	OverflowDropOldest OverflowPolicy = iota
+	OverflowDropNewest
+	OverflowBlock
+)
+
+func ParseOverflowPolicy(value string) (OverflowPolicy, error) {
+	switch value {
+	case "", "drop-oldest":
+		return OverflowDropOldest, nil
+	case "drop-newest":
+		return OverflowDropNewest, nil
+	case "block":
+		return OverflowBlock, nil
+	default:
+		return 0, fmt.Errorf("invalid overflow policy %q (want block, drop-oldest or drop-newest)", value)
+	}
+}
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowBlock:
+		return "block"
+	default:
+		return "drop-oldest"
+	}
+}
+
+type Stats struct {
+	Queued  int
+	Dropped int64
+	// DroppedByPath counts dropped lines per display path.
+	DroppedByPath map[string]int64
+}
+
+type dropStats struct {
+	total   int64
+	byPath  map[string]int64
+	pending map[string]*pendingDrop
+}
+
+type pendingDrop struct {
+	path    string
+	absPath string
+	count   int64
+}
+
+func (t *Tailer) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := Stats{
+		Queued:        len(t.lines),
+		Dropped:       t.drops.total,
+		DroppedByPath: make(map[string]int64, len(t.drops.byPath)),
+	}
+	for path, count := range t.drops.byPath {
+		stats.DroppedByPath[path] = count
+	}
+	return stats
+}
+
+func (t *Tailer) sendLine(line Line) {
+	t.publish(line)
+	// Subscribers get the file's text; the ANSI mode is for Lines(), which
+	// the CLI writes to a terminal.
+	if !line.Marker {
+		line.Text = applyANSI(t.cfg.ANSI, line.Text)
+	}
+	if t.cfg.Overflow == OverflowBlock {
+		select {
+		case t.lines <- line:
+		case <-t.stop:
+		}
+		return
+	}
+
+	t.flushDrops()
+	select {
+	case t.lines <- line:
+		return
+	default:
+	}
+	if t.cfg.Overflow == OverflowDropNewest {
+		t.recordDrop(line)
+		return
+	}
+	select {
+	case dropped := <-t.lines:
+		t.recordDrop(dropped)
+	default:
+	}
+	select {
+	case t.lines <- line:
+	default:
+		t.recordDrop(line)
+	}
+}
+
+func (t *Tailer) recordDrop(line Line) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.drops.byPath == nil {
+		t.drops.byPath = make(map[string]int64)
+		t.drops.pending = make(map[string]*pendingDrop)
+	}
+	t.drops.total++
+	t.drops.byPath[line.Path]++
+	pending, ok := t.drops.pending[line.Path]
+	if !ok {
+		pending = &pendingDrop{path: line.Path, absPath: line.AbsPath}
+		t.drops.pending[line.Path] = pending
+	}
+	pending.count++
+}
+
+// flushDrops queues a marker for every file that lost lines since the last
+// flush, as long as there is room for all of them ahead of the next line.
+func (t *Tailer) flushDrops() {
+	t.mu.Lock()
+	if len(t.drops.pending) == 0 || cap(t.lines)-len(t.lines) <= len(t.drops.pending) {
+		t.mu.Unlock()
+		return
+	}
+	pending := make([]*pendingDrop, 0, len(t.drops.pending))
+	for _, drop := range t.drops.pending {
+		pending = append(pending, drop)
+	}
+	clear(t.drops.pending)
+	t.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].path < pending[j].path
+	})
+	now := time.Now()
+	for _, drop := range pending {
+		marker := Line{
+			Path:    drop.path,
+			AbsPath: drop.absPath,
+			Text:    fmt.Sprintf("[ft: %d lines dropped from %s]", drop.count, drop.path),
+			Time:    now,
+			Marker:  true,
+		}
+		select {
+		case t.lines <- marker:
+		default:
+		}
+	}
+}
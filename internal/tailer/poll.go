@@ -0,0 +1,207 @@
+package tailer
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type pollEntry struct {
+	dir     bool
+	size    int64
+	modTime time.Time
+	id      fileID
+	hasID   bool
+}
+
+// pollWatcher stats the entries of each watched directory on an interval and
+// turns differences into fsnotify events. A replaced file (new identity) is
+// reported as Remove followed by Create so rotation handling kicks in.
+type pollWatcher struct {
+	interval time.Duration
+	events   chan fsnotify.Event
+	errors   chan error
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	dirs     map[string]map[string]pollEntry
+}
+
+func newPollWatcher(interval time.Duration) *pollWatcher {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	w := &pollWatcher{
+		interval: interval,
+		events:   make(chan fsnotify.Event, 256),
+		errors:   make(chan error, 16),
+		done:     make(chan struct{}),
+		dirs:     make(map[string]map[string]pollEntry),
+	}
+	go w.run()
+	return w
+}
+
+func (w *pollWatcher) Add(path string) error {
+	snapshot, err := pollDir(path)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.dirs[path]; !ok {
+		w.dirs[path] = snapshot
+	}
+	return nil
+}
+
+func (w *pollWatcher) Remove(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.dirs, path)
+	return nil
+}
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollWatcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+func (w *pollWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *pollWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if !w.poll() {
+				return
+			}
+		}
+	}
+}
+
+// poll scans every directory without holding the lock, so Add and Remove from
+// the event loop never wait behind a slow mount or a full events channel.
+func (w *pollWatcher) poll() bool {
+	w.mu.Lock()
+	dirs := make([]string, 0, len(w.dirs))
+	for dir := range w.dirs {
+		dirs = append(dirs, dir)
+	}
+	w.mu.Unlock()
+
+	for _, dir := range dirs {
+		current, err := pollDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			if !w.send(nil, err) {
+				return false
+			}
+			continue
+		}
+
+		w.mu.Lock()
+		previous, ok := w.dirs[dir]
+		if ok {
+			if current == nil {
+				delete(w.dirs, dir)
+			} else {
+				w.dirs[dir] = current
+			}
+		}
+		w.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		if current == nil {
+			if !w.send(&fsnotify.Event{Name: dir, Op: fsnotify.Remove}, nil) {
+				return false
+			}
+			continue
+		}
+		for _, event := range diffPoll(dir, previous, current) {
+			if !w.send(&event, nil) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (w *pollWatcher) send(event *fsnotify.Event, err error) bool {
+	if event != nil {
+		select {
+		case w.events <- *event:
+			return true
+		case <-w.done:
+			return false
+		}
+	}
+	select {
+	case w.errors <- err:
+	default:
+	}
+	return true
+}
+
+func diffPoll(dir string, previous, current map[string]pollEntry) []fsnotify.Event {
+	var events []fsnotify.Event
+	for name, old := range previous {
+		entry, ok := current[name]
+		path := filepath.Join(dir, name)
+		switch {
+		case !ok:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		case old.dir != entry.dir || old.hasID && entry.hasID && old.id != entry.id:
+			events = append(events,
+				fsnotify.Event{Name: path, Op: fsnotify.Remove},
+				fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case !entry.dir && (old.size != entry.size || !old.modTime.Equal(entry.modTime)):
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for name := range current {
+		if _, ok := previous[name]; !ok {
+			events = append(events, fsnotify.Event{Name: filepath.Join(dir, name), Op: fsnotify.Create})
+		}
+	}
+	return events
+}
+
+func pollDir(dir string) (map[string]pollEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(map[string]pollEntry, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		id, hasID := fileIdentity(info)
+		snapshot[entry.Name()] = pollEntry{
+			dir:     info.IsDir(),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			id:      id,
+			hasID:   hasID,
+		}
+	}
+	return snapshot, nil
+}
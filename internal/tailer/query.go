@@ -0,0 +1,464 @@
+package tailer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a compiled -where expression, for example
+//
+//	level>=warn and service=="api" and (latency_ms>500 or duration>1.5s)
+//
+// Fields come from Line.Fields (dotted keys reach into nested JSON); level
+// is the detected level, and path and text fall back to the display path
+// and the line without escape sequences.
+type Query struct {
+	src  string
+	root queryNode
+}
+
+type QueryError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos+1, e.Msg)
+}
+
+// Context shows the expression with a caret under the failing column.
+func (e *QueryError) Context() string {
+	return e.Query + "\n" + strings.Repeat(" ", e.Pos) + "^"
+}
+
+func ParseQuery(src string) (*Query, error) {
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{src: src, tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorf(p.peek(), "empty expression")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		if tok.kind == tokRParen {
+			return nil, p.errorf(tok, "unexpected \")\" without matching \"(\"")
+		}
+		return nil, p.errorf(tok, "expected \"and\" or \"or\" before %s", tok)
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+func (q *Query) Match(line Line) bool {
+	return q.root.eval(line)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokRegex
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t queryToken) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+var queryOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "<", ">", "="}
+
+func lexQuery(src string) ([]queryToken, error) {
+	var tokens []queryToken
+	errorf := func(pos int, format string, args ...any) error {
+		return &QueryError{Query: src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{tokRParen, ")", i})
+			i++
+		case strings.HasPrefix(src[i:], "&&"):
+			tokens = append(tokens, queryToken{tokAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(src[i:], "||"):
+			tokens = append(tokens, queryToken{tokOr, "||", i})
+			i += 2
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, errorf(i, "unterminated string")
+			}
+			text := src[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(src[i : end+1])
+				if err != nil {
+					return nil, errorf(i, "invalid string %s: %v", src[i:end+1], err)
+				}
+				text = unquoted
+			}
+			tokens = append(tokens, queryToken{tokString, text, i})
+			i = end + 1
+		case c == '/' && len(tokens) > 0 && tokens[len(tokens)-1].kind == tokOp:
+			end := i + 1
+			for end < len(src) && src[end] != '/' {
+				if src[end] == '\\' && end+1 < len(src) && src[end+1] == '/' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, errorf(i, "unterminated regex")
+			}
+			tokens = append(tokens, queryToken{tokRegex, strings.ReplaceAll(src[i+1:end], `\/`, "/"), i})
+			i = end + 1
+		case strings.ContainsRune("=!<>", rune(c)):
+			if strings.HasPrefix(src[i:], "=>") || strings.HasPrefix(src[i:], "=<") {
+				return nil, errorf(i, "unknown operator %q (did you mean %q?)", src[i:i+2], string(src[i+1])+"=")
+			}
+			op := ""
+			for _, candidate := range queryOps {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				tokens = append(tokens, queryToken{tokNot, "!", i})
+				i++
+				continue
+			}
+			tokens = append(tokens, queryToken{tokOp, op, i})
+			i += len(op)
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			end := i + 1
+			for end < len(src) && isValueByte(src[end]) {
+				end++
+			}
+			text := src[i:end]
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				tokens = append(tokens, queryToken{tokNumber, text, i})
+			} else if _, err := time.ParseDuration(text); err == nil {
+				tokens = append(tokens, queryToken{tokDuration, text, i})
+			} else {
+				return nil, errorf(i, "invalid number or duration %q (quote it to compare as a string)", text)
+			}
+			i = end
+		case isIdentStart(rune(c)):
+			end := i + 1
+			for end < len(src) && isValueByte(src[end]) {
+				end++
+			}
+			text := src[i:end]
+			kind := tokIdent
+			switch strings.ToLower(text) {
+			case "and":
+				kind = tokAnd
+			case "or":
+				kind = tokOr
+			case "not":
+				kind = tokNot
+			}
+			tokens = append(tokens, queryToken{kind, text, i})
+			i = end
+		default:
+			return nil, errorf(i, "unexpected character %q", c)
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '@'
+}
+
+func isValueByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '@' || c == '-' || c >= 0x80
+}
+
+type queryParser struct {
+	src    string
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) errorf(tok queryToken, format string, args ...any) error {
+	return &QueryError{Query: p.src, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "expected \")\" to close \"(\" at column %d, found %s", tok.pos+1, closing)
+		}
+		return inner, nil
+	case tokIdent:
+	case tokEOF:
+		return nil, p.errorf(tok, "expected a field name, found end of expression")
+	default:
+		return nil, p.errorf(tok, "expected a field name, found %s", tok)
+	}
+	field := tok.text
+	if p.peek().kind != tokOp {
+		return existsNode{field}, nil
+	}
+	opTok := p.next()
+	op := opTok.text
+	if op == "=" {
+		op = "=="
+	}
+	valueTok := p.next()
+	switch valueTok.kind {
+	case tokString, tokNumber, tokDuration, tokIdent, tokRegex:
+	default:
+		return nil, p.errorf(valueTok, "expected a value after %q, found %s", opTok.text, valueTok)
+	}
+	node := compareNode{field: field, op: op, value: valueTok.text}
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile(valueTok.text)
+		if err != nil {
+			return nil, p.errorf(valueTok, "invalid regex: %v", err)
+		}
+		node.re = re
+		return node, nil
+	}
+	if valueTok.kind == tokRegex {
+		return nil, p.errorf(valueTok, "a /regex/ needs =~ or !~, not %q", opTok.text)
+	}
+	switch valueTok.kind {
+	case tokNumber:
+		node.num, _ = strconv.ParseFloat(valueTok.text, 64)
+		node.kind = compareNumber
+	case tokDuration:
+		node.dur, _ = time.ParseDuration(valueTok.text)
+		node.kind = compareDuration
+	}
+	if field == "level" {
+		level, err := ParseLevel(valueTok.text)
+		if err != nil || level == LevelUnknown {
+			return nil, p.errorf(valueTok, "unknown level %q (want trace, debug, info, warn, error or fatal)", valueTok.text)
+		}
+		node.level = level
+		node.kind = compareLevel
+	}
+	return node, nil
+}
+
+type queryNode interface {
+	eval(line Line) bool
+}
+
+type andNode struct{ left, right queryNode }
+
+func (n andNode) eval(line Line) bool { return n.left.eval(line) && n.right.eval(line) }
+
+type orNode struct{ left, right queryNode }
+
+func (n orNode) eval(line Line) bool { return n.left.eval(line) || n.right.eval(line) }
+
+type notNode struct{ inner queryNode }
+
+func (n notNode) eval(line Line) bool { return !n.inner.eval(line) }
+
+type existsNode struct{ field string }
+
+func (n existsNode) eval(line Line) bool {
+	_, ok := lookupField(line, n.field)
+	return ok
+}
+
+type compareKind int
+
+const (
+	compareString compareKind = iota
+	compareNumber
+	compareDuration
+	compareLevel
+)
+
+type compareNode struct {
+	field string
+	op    string
+	kind  compareKind
+	value string
+	num   float64
+	dur   time.Duration
+	level Level
+	re    *regexp.Regexp
+}
+
+// eval compares the field with the value. A missing field, or one that
+// does not parse as the value's type, only satisfies != and !~.
+func (n compareNode) eval(line Line) bool {
+	negated := n.op == "!=" || n.op == "!~"
+	if n.kind == compareLevel && line.Level != LevelUnknown {
+		return compareOrdered(n.op, int(line.Level), int(n.level))
+	}
+	value, ok := lookupField(line, n.field)
+	if !ok {
+		return negated
+	}
+	if n.re != nil {
+		return n.re.MatchString(value) != negated
+	}
+	switch n.kind {
+	case compareNumber:
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return negated
+		}
+		return compareOrdered(n.op, num, n.num)
+	case compareDuration:
+		dur, err := time.ParseDuration(value)
+		if err != nil {
+			return negated
+		}
+		return compareOrdered(n.op, dur, n.dur)
+	case compareLevel:
+		level, ok := levelNames[strings.ToLower(value)]
+		if !ok {
+			return negated
+		}
+		return compareOrdered(n.op, int(level), int(n.level))
+	default:
+		return compareOrdered(n.op, value, n.value)
+	}
+}
+
+func compareOrdered[T int | float64 | time.Duration | string](op string, a, b T) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+func lookupField(line Line, field string) (string, bool) {
+	if field == "level" && line.Level != LevelUnknown {
+		return line.Level.String(), true
+	}
+	if value, ok := line.Fields.Get(field); ok {
+		return FormatValue(value), true
+	}
+	switch field {
+	case "path":
+		return line.Path, true
+	case "text":
+		return StripANSI(line.Text), true
+	}
+	return "", false
+}
@@ -0,0 +1,250 @@
+package tailer
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errZstdUnsupported = errors.New("zstd compressed backlog is not supported")
+
+// rotatedSiblings lists the rotated generations of path, newest first:
+// logrotate style (app.log.1, app.log.2.gz), dated suffixes
+// (app.log-20261015, app.log.2026-10-15.gz) and dated names that keep the
+// extension (app-2026-10-15.log.zst).
+func rotatedSiblings(path string) ([]string, error) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	type sibling struct {
+		path  string
+		num   int
+		isNum bool
+		mod   int64
+	}
+	var found []sibling
+	for _, entry := range entries {
+		candidate := entry.Name()
+		if candidate == name || !entry.Type().IsRegular() {
+			continue
+		}
+		base := trimCompression(candidate)
+		var suffix string
+		switch {
+		case strings.HasPrefix(base, name) && len(base) > len(name) && isRotationSep(base[len(name)]):
+			suffix = base[len(name)+1:]
+		case ext != "" && strings.HasPrefix(base, stem) && strings.HasSuffix(base, ext) &&
+			len(base) > len(name)+1 && isRotationSep(base[len(stem)]):
+			suffix = strings.TrimSuffix(base[len(stem)+1:], ext)
+		default:
+			continue
+		}
+		if !isGeneration(suffix) && !isDateSuffix(suffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s := sibling{path: filepath.Join(dir, candidate), mod: info.ModTime().UnixNano()}
+		if isGeneration(suffix) {
+			s.num, _ = strconv.Atoi(suffix)
+			s.isNum = true
+		}
+		found = append(found, s)
+	}
+
+	// Order everything by mtime, then put the numbered generations back into
+	// their slots by number, which stays right even when mtimes were touched.
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].mod != found[j].mod {
+			return found[i].mod > found[j].mod
+		}
+		return found[i].path > found[j].path
+	})
+	var slots []int
+	var numbered []sibling
+	for i, s := range found {
+		if s.isNum {
+			slots = append(slots, i)
+			numbered = append(numbered, s)
+		}
+	}
+	sort.Slice(numbered, func(i, j int) bool { return numbered[i].num < numbered[j].num })
+	for i, slot := range slots {
+		found[slot] = numbered[i]
+	}
+	paths := make([]string, len(found))
+	for i, s := range found {
+		paths[i] = s.path
+	}
+	return paths, nil
+}
+
+func isRotationSep(b byte) bool {
+	return b == '.' || b == '-' || b == '_'
+}
+
+// isGeneration matches logrotate's numbered copies (app.log.1).
+func isGeneration(s string) bool {
+	if s == "" || len(s) > 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDateSuffix accepts dates and timestamps made of digits and separators.
+func isDateSuffix(s string) bool {
+	digits := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '-' || r == '_' || r == '.' || r == 'T':
+		default:
+			return false
+		}
+	}
+	return digits >= 6
+}
+
+func trimCompression(name string) string {
+	for _, ext := range []string{".gz", ".bz2", ".zst"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// lastLinesOf returns up to n trailing lines of a rotated file, decompressing
+// it when needed. Lines are split in the file's encoding either way, so a
+// compressed UTF-16 sibling decodes like a plain one. A trailing line without
+// a newline counts as complete, since a rotated file no longer grows.
+func lastLinesOf(path string, n int, enc *textEncoding) ([]string, []int64, error) {
+	if strings.HasSuffix(path, ".zst") {
+		return nil, nil, errZstdUnsupported
+	}
+	if !strings.HasSuffix(path, ".gz") && !strings.HasSuffix(path, ".bz2") {
+		lines, partial, offsets, err := readLastLines(path, n, enc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(partial) > 0 {
+			text, _ := enc.text(partial, 0, false)
+			lines = append(lines, text)
+		}
+		return lines, offsets[:len(lines)], nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	var reader io.Reader
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	} else {
+		reader = bzip2.NewReader(file)
+	}
+
+	// Compressed files cannot be read backwards, so keep a ring of the last n.
+	lines := make([]string, 0, n)
+	offsets := make([]int64, 0, n)
+	var pending []byte
+	var start int64
+	keep := func(line []byte) {
+		text, _ := enc.text(line, 0, start == 0)
+		if len(lines) == n {
+			copy(lines, lines[1:])
+			copy(offsets, offsets[1:])
+			lines, offsets = lines[:n-1], offsets[:n-1]
+		}
+		lines = append(lines, text)
+		offsets = append(offsets, start)
+	}
+	buf := make([]byte, readChunkSize)
+	for {
+		read, err := reader.Read(buf)
+		pending = append(pending, buf[:read]...)
+		for {
+			idx := enc.indexNewline(pending)
+			if idx < 0 {
+				break
+			}
+			keep(pending[:idx])
+			start += int64(idx + enc.newlineWidth())
+			pending = pending[idx+enc.newlineWidth():]
+		}
+		if errors.Is(err, io.EOF) {
+			if len(pending) > 0 {
+				keep(pending)
+			}
+			return lines, offsets, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// emitRotatedBacklog fills up to n lines of backlog from rotated siblings,
+// oldest first, ahead of the current file's own backlog.
+func (t *Tailer) emitRotatedBacklog(path string, state *fileState, n int) {
+	siblings, err := rotatedSiblings(path)
+	if err != nil {
+		t.sendErr(OpWalk, filepath.Dir(path), err)
+		return
+	}
+	type chunk struct {
+		path    string
+		lines   []string
+		offsets []int64
+	}
+	var chunks []chunk
+	for _, sibling := range siblings {
+		if n <= 0 {
+			break
+		}
+		lines, offsets, err := lastLinesOf(sibling, n, state.enc)
+		if err != nil {
+			// Stop here so the backlog has no gap. Rotation re-runs the
+			// backfill, so report an unreadable sibling once.
+			if _, seen := t.rotErrs[sibling]; !seen {
+				t.rotErrs[sibling] = struct{}{}
+				t.sendErr(OpRead, sibling, err)
+			}
+			break
+		}
+		chunks = append(chunks, chunk{path: sibling, lines: lines, offsets: offsets})
+		n -= len(lines)
+	}
+	display := t.displayPath(path)
+	for i := len(chunks) - 1; i >= 0; i-- {
+		for j, line := range chunks[i].lines {
+			t.emit(state, chunks[i].path, Line{Path: display, Text: line, Offset: chunks[i].offsets[j]})
+		}
+	}
+}
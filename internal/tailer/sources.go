@@ -0,0 +1,131 @@
+package tailer
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Source struct {
+	Path  string
+	Label string
+}
+
+type source struct {
+	path  string
+	label string
+	dir   bool
+}
+
+func resolveSources(cfg Config) ([]source, error) {
+	specs := cfg.Sources
+	if len(specs) == 0 {
+		root := cfg.Root
+		if root == "" {
+			root = "."
+		}
+		specs = []Source{{Path: root}}
+	}
+
+	sources := make([]source, 0, len(specs))
+	for _, spec := range specs {
+		abs, err := filepath.Abs(spec.Path)
+		if err != nil {
+			return nil, err
+		}
+		src := source{path: abs, label: spec.Label}
+		if info, err := os.Stat(abs); err == nil && info.IsDir() {
+			src.dir = true
+		}
+		if src.label == "" && (!src.dir || len(specs) > 1) {
+			src.label = filepath.Clean(spec.Path)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// checkSources fails on directory sources that cannot be listed, so Start
+// reports them synchronously whatever the overflow policy.
+func (t *Tailer) checkSources() error {
+	for _, src := range t.sourceList() {
+		if !src.dir {
+			continue
+		}
+		dir, err := os.Open(src.path)
+		if err != nil {
+			return err
+		}
+		_, err = dir.ReadDir(1)
+		dir.Close()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tailer) sourceList() []source {
+	if len(t.sources) > 0 {
+		return t.sources
+	}
+	return []source{{path: t.cfg.Root, dir: true}}
+}
+
+// sourceFor returns the source a path belongs to and the path relative to it.
+// Explicit file sources win over directories; among directories the deepest
+// root wins.
+func (t *Tailer) sourceFor(path string) (*source, string) {
+	sources := t.sourceList()
+	var best *source
+	for i := range sources {
+		src := &sources[i]
+		if !src.dir {
+			if src.path == path {
+				return src, filepath.Base(path)
+			}
+			continue
+		}
+		if path != src.path && !strings.HasPrefix(path, src.path+string(os.PathSeparator)) {
+			continue
+		}
+		if best == nil || len(src.path) > len(best.path) {
+			best = src
+		}
+	}
+	if best == nil {
+		return nil, ""
+	}
+	rel, err := filepath.Rel(best.path, path)
+	if err != nil {
+		rel = path
+	}
+	return best, rel
+}
+
+func (t *Tailer) isExplicitFile(path string) bool {
+	src, _ := t.sourceFor(path)
+	return src != nil && !src.dir
+}
+
+func (t *Tailer) scanFile(path string, seenFiles, seenDirs map[string]struct{}) {
+	dir := filepath.Dir(path)
+	seenDirs[dir] = struct{}{}
+	if err := t.addWatch(dir); err != nil {
+		t.sendErr(OpWatch, dir, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	seenFiles[path] = struct{}{}
+	if state := t.getState(path); state != nil {
+		if err := t.readNew(path, state); err != nil {
+			t.sendErr(OpRead, path, err)
+		}
+		return
+	}
+	t.ensureFile(path)
+}
@@ -0,0 +1,162 @@
+package tailer
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultSubscriberBuffer = 1024
+
+// Filter describes what a subscriber receives and how its queue behaves.
+type Filter struct {
+	// Match selects lines (markers included); nil receives every line. It
+	// runs on the tailer's goroutine and must not block.
+	Match func(Line) bool
+	// Buffer is the subscriber's queue size (default 1024).
+	Buffer int
+	// Overflow decides what happens when the queue is full. Block only
+	// stalls the tailer for as long as this subscriber is behind.
+	Overflow OverflowPolicy
+}
+
+type subscriber struct {
+	filter  Filter
+	lines   chan Line
+	done    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// Subscribe returns a stream of the lines that pass the filter, independent
+// of Lines() and of other subscribers, and a function that ends the
+// subscription and closes the channel. The channel is also closed when the
+// tailer stops. Subscribers get the file's text; Config.ANSI only applies to
+// Lines().
+func (t *Tailer) Subscribe(filter Filter) (<-chan Line, func()) {
+	if filter.Buffer <= 0 {
+		filter.Buffer = defaultSubscriberBuffer
+	}
+	s := &subscriber{
+		filter: filter,
+		lines:  make(chan Line, filter.Buffer),
+		done:   make(chan struct{}),
+	}
+	t.subMu.Lock()
+	if t.subsClosed {
+		t.subMu.Unlock()
+		close(s.lines)
+		return s.lines, func() {}
+	}
+	t.subs = append(t.subs, s)
+	t.subMu.Unlock()
+
+	var once sync.Once
+	return s.lines, func() {
+		once.Do(func() {
+			close(s.done)
+			t.subMu.Lock()
+			t.subs = slices.DeleteFunc(t.subs, func(other *subscriber) bool { return other == s })
+			t.subMu.Unlock()
+			s.close()
+		})
+	}
+}
+
+func (t *Tailer) publish(line Line) {
+	t.subMu.Lock()
+	subs := slices.Clone(t.subs)
+	t.subMu.Unlock()
+	for _, s := range subs {
+		s.send(line, t.stop)
+	}
+}
+
+func (t *Tailer) flushSubscriberDrops() {
+	t.subMu.Lock()
+	subs := slices.Clone(t.subs)
+	t.subMu.Unlock()
+	for _, s := range subs {
+		s.mu.Lock()
+		if !s.closed {
+			s.flushDrops()
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (t *Tailer) closeSubscribers() {
+	t.subMu.Lock()
+	subs := t.subs
+	t.subs = nil
+	t.subsClosed = true
+	t.subMu.Unlock()
+	for _, s := range subs {
+		s.close()
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.lines)
+	}
+}
+
+func (s *subscriber) send(line Line, stop <-chan struct{}) {
+	if s.filter.Match != nil && !s.filter.Match(line) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.filter.Overflow == OverflowBlock {
+		select {
+		case s.lines <- line:
+		case <-s.done:
+		case <-stop:
+		}
+		return
+	}
+
+	s.flushDrops()
+	select {
+	case s.lines <- line:
+		return
+	default:
+	}
+	if s.filter.Overflow == OverflowDropNewest {
+		s.dropped++
+		return
+	}
+	select {
+	case <-s.lines:
+		s.dropped++
+	default:
+	}
+	select {
+	case s.lines <- line:
+	default:
+		s.dropped++
+	}
+}
+
+// flushDrops queues a marker for lines the subscriber lost once there is
+// room for it ahead of the next line.
+func (s *subscriber) flushDrops() {
+	if s.dropped == 0 || cap(s.lines)-len(s.lines) < 2 {
+		return
+	}
+	s.lines <- Line{
+		Text:   fmt.Sprintf("[ft: %d lines dropped for this subscriber]", s.dropped),
+		Time:   time.Now(),
+		Marker: true,
+	}
+	s.dropped = 0
+}
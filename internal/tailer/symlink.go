@@ -0,0 +1,104 @@
+package tailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var errSymlinkLoop = errors.New("symlink loop")
+
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// followDir decides whether a directory reached while following symlinks
+// should be watched and walked. A directory already watched under another
+// path is skipped, since inotify keeps a single watch per inode and would
+// report its events under only one name; when that other path is an ancestor
+// the link is a loop. A path whose target changed since the last scan (a
+// retargeted "current" link) is dropped first so it is picked up afresh.
+func (t *Tailer) followDir(path string, info os.FileInfo) bool {
+	id, ok := fileIdentity(info)
+	if !ok {
+		// Without device+inode there is no way to tell a loop from a new directory.
+		return !isSymlink(path)
+	}
+	if old, ok := t.dirIDs[path]; ok && old != id {
+		t.removePath(path)
+	}
+	if owner, ok := t.dirOwners[id]; ok && owner != path {
+		if _, err := os.Stat(owner); err == nil {
+			if strings.HasPrefix(path, owner+string(os.PathSeparator)) {
+				t.reportLoop(path, owner)
+			}
+			return false
+		}
+		t.forgetDir(owner)
+	}
+	t.dirIDs[path] = id
+	t.dirOwners[id] = path
+	return true
+}
+
+// forgetDir drops a directory from the identity index in both directions.
+func (t *Tailer) forgetDir(path string) {
+	if id, ok := t.dirIDs[path]; ok {
+		if t.dirOwners[id] == path {
+			delete(t.dirOwners, id)
+		}
+		delete(t.dirIDs, path)
+	}
+}
+
+func (t *Tailer) reportLoop(path, target string) {
+	if _, ok := t.loops[path]; ok {
+		return
+	}
+	t.loops[path] = struct{}{}
+	t.sendErr(OpWalk, path, fmt.Errorf("%w back to %s", errSymlinkLoop, target))
+}
+
+// duplicateFile reports whether the file at path is already tailed under
+// another name, e.g. both as releases/v2/app.log and current/app.log.
+func (t *Tailer) duplicateFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	id, ok := fileIdentity(info)
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for other, state := range t.states {
+		if other != path && state.hasID && state.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// walkLink follows a symlink met during a recursive walk. Directories are
+// walked through the link path so display paths and watcher events keep the
+// name the user sees.
+func (t *Tailer) walkLink(path string, seenFiles, seenDirs map[string]struct{}) error {
+	if !t.cfg.FollowSymlinks {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if info.Mode().IsRegular() {
+		t.scanEntry(path, seenFiles)
+		return nil
+	}
+	if !info.IsDir() || !t.followDir(path, info) {
+		return nil
+	}
+	return t.walkRoot(path+string(os.PathSeparator), seenFiles, seenDirs)
+}
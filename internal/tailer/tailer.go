@@ -10,6 +10,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -40,42 +41,161 @@ type pattern struct {
 }
 
 type Config struct {
-	Root         string
-	N            int
-	FromStart    bool
-	ScanInterval time.Duration
-	Absolute     bool
-	Include      []string
-	Exclude      []string
-	ForceRegex   bool
-	Recursive    bool
-	RecursiveSet bool
-	MaxLineBytes int
+	Root               string
+	Sources            []Source
+	N                  int
+	FromStart          bool
+	ScanInterval       time.Duration
+	Absolute           bool
+	Include            []string
+	Exclude            []string
+	ForceRegex         bool
+	Recursive          bool
+	RecursiveSet       bool
+	MaxLineBytes       int
+	CheckpointPath     string
+	CheckpointInterval time.Duration
+	Resume             bool
+	Grep               []string
+	GrepExclude        []string
+	GrepFixed          bool
+	GrepIgnoreCase     bool
+	GrepBefore         int
+	GrepAfter          int
+	Overflow           OverflowPolicy
+	Watch              WatchMode
+	FollowSymlinks     bool
+	Order              Order
+	TimeLayout         string
+	ReorderWindow      time.Duration
+	MultilineStart     string
+	MultilineIndent    bool
+	MultilineTimeout   time.Duration
+	RotatedBacklog     bool
+	Encoding           []string
+	ANSI               ANSIMode
+	PollInterval       time.Duration
+	MinLevel           Level
+	Fields             FieldFormat
+	Where              string
 }
 
 type Line struct {
 	Path    string
+	AbsPath string
 	Text    string
+	Offset  int64
+	LineNo  int64
+	Time    time.Time
+	// Timestamp is the time parsed from the line (or inherited from the
+	// previous line of the file) when ordering by time.
+	Timestamp time.Time
+	// Level is the detected log level, inherited from the previous line of
+	// the file when the line has none.
+	Level Level
+	// Fields holds the keys of a JSON object or logfmt line, in order.
+	Fields  Fields
 	Partial bool
 	Update  bool
+	Marker  bool
+}
+
+type FileStat struct {
+	Path         string
+	AbsPath      string
+	Lines        int64
+	LastActivity time.Time
+}
+
+type filterUpdate struct {
+	include    []string
+	exclude    []string
+	forceRegex bool
+	includes   []pattern
+	excludes   []pattern
+}
+
+type fileID struct {
+	dev uint64
+	ino uint64
 }
 
 type fileState struct {
 	offset           int64
 	partial          []byte
 	partialDisplayed bool
+	lineStart        int64
+	lineNo           int64
+	lineNoKnown      bool
+	file             *os.File
+	id               fileID
+	hasID            bool
+	grep             grepState
+	lineCount        int64
+	lastActivity     time.Time
+	lastStamp        time.Time
+	lastLevel        Level
+	record           *pendingRecord
+	enc              *textEncoding
+}
+
+func (s *fileState) close() {
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+}
+
+func (s *fileState) nextLineNo() int64 {
+	if !s.lineNoKnown {
+		return 0
+	}
+	return s.lineNo + 1
+}
+
+func (s *fileState) reset() {
+	s.close()
+	s.offset = 0
+	s.lineStart = 0
+	s.lineNo = 0
+	s.lineNoKnown = false
+	s.partial = nil
+	s.partialDisplayed = false
+	s.id = fileID{}
+	s.hasID = false
 }
 
 type Tailer struct {
 	cfg        Config
-	watcher    *fsnotify.Watcher
+	watcher    watcher
+	poller     *pollWatcher
 	lines      chan Line
-	errs       chan error
+	errs       chan *Error
 	done       chan struct{}
 	states     map[string]*fileState
-	watchedDir map[string]struct{}
+	watchedDir map[string]watcher
+	dirIDs     map[string]fileID
+	dirOwners  map[fileID]string
+	loops      map[string]struct{}
+	removed    map[string]struct{}
+	sources    []source
 	includes   []pattern
 	excludes   []pattern
+	saved      map[string]checkpoint
+	rotErrs    map[string]struct{}
+	grep       *lineFilter
+	where      *Query
+	filterCh   chan struct{}
+	filters    *filterUpdate
+	stop       <-chan struct{}
+	drops      dropStats
+	errHistory errorHistory
+	reorder    *reorderBuffer
+	multiline  *multiline
+	encodings  []encodingRule
+	subs       []*subscriber
+	subsClosed bool
+	subMu      sync.Mutex
 	mu         sync.Mutex
 }
 
@@ -134,15 +254,14 @@ var binarySuffixes = []string{
 }
 
 func New(cfg Config) (*Tailer, error) {
-	root := cfg.Root
-	if root == "" {
-		root = "."
-	}
-	abs, err := filepath.Abs(root)
+	sources, err := resolveSources(cfg)
 	if err != nil {
 		return nil, err
 	}
-	cfg.Root = abs
+	cfg.Root = sources[0].path
+	if !sources[0].dir {
+		cfg.Root = filepath.Dir(sources[0].path)
+	}
 	if !cfg.RecursiveSet {
 		cfg.Recursive = true
 	}
@@ -159,29 +278,82 @@ func New(cfg Config) (*Tailer, error) {
 		return nil, err
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	grep, err := compileLineFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var where *Query
+	if cfg.Where != "" {
+		where, err = ParseQuery(cfg.Where)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where expression: %w", err)
+		}
+	}
+	multi, err := compileMultiline(cfg)
+	if err != nil {
+		return nil, err
+	}
+	encodings, err := parseEncodingRules(cfg.Encoding, cfg.ForceRegex)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Tailer{
+	var saved map[string]checkpoint
+	if cfg.CheckpointPath != "" {
+		if cfg.CheckpointInterval <= 0 {
+			cfg.CheckpointInterval = defaultCheckpointInterval
+		}
+		saved, err = loadCheckpoints(cfg.CheckpointPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Without fsnotify (e.g. out of inotify instances) auto mode polls everything.
+	var w watcher
+	var watchErr error
+	if cfg.Watch != WatchPoll {
+		w, watchErr = newFSNotifyWatcher()
+		if watchErr != nil && cfg.Watch == WatchFSNotify {
+			return nil, watchErr
+		}
+	}
+
+	t := &Tailer{
 		cfg:        cfg,
-		watcher:    watcher,
+		watcher:    w,
 		lines:      make(chan Line, 4096),
-		errs:       make(chan error, 64),
+		errs:       make(chan *Error, 64),
 		done:       make(chan struct{}),
 		states:     make(map[string]*fileState),
-		watchedDir: make(map[string]struct{}),
+		watchedDir: make(map[string]watcher),
+		dirIDs:     make(map[string]fileID),
+		dirOwners:  make(map[fileID]string),
+		loops:      make(map[string]struct{}),
+		removed:    make(map[string]struct{}),
+		sources:    sources,
 		includes:   includes,
 		excludes:   excludes,
-	}, nil
+		saved:      saved,
+		rotErrs:    make(map[string]struct{}),
+		grep:       grep,
+		filterCh:   make(chan struct{}, 1),
+		reorder:    newReorderBuffer(cfg),
+		multiline:  multi,
+		where:      where,
+		encodings:  encodings,
+	}
+	if watchErr != nil {
+		t.sendErr(OpWatch, "", fmt.Errorf("%w: %w", errPollFallback, watchErr))
+	}
+	return t, nil
 }
 
 func (t *Tailer) Lines() <-chan Line {
 	return t.lines
 }
 
-func (t *Tailer) Errors() <-chan error {
+func (t *Tailer) Errors() <-chan *Error {
 	return t.errs
 }
 
@@ -189,30 +361,139 @@ func (t *Tailer) Done() <-chan struct{} {
 	return t.done
 }
 
+// Discard releases a tailer that was never started: it closes open files,
+// the watcher and the channels.
+// A started tailer stops when the channel passed to Start is closed.
+func (t *Tailer) Discard() error {
+	var err error
+	if t.watcher != nil {
+		err = t.watcher.Close()
+	}
+	if t.poller != nil {
+		_ = t.poller.Close()
+	}
+	t.closeStates()
+	close(t.lines)
+	t.closeSubscribers()
+	close(t.errs)
+	close(t.done)
+	return err
+}
+
+func (t *Tailer) closeStates() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, state := range t.states {
+		state.close()
+	}
+}
+
 func (t *Tailer) FileCount() int {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	return len(t.states)
 }
 
+func (t *Tailer) Files() []FileStat {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := make([]FileStat, 0, len(t.states))
+	for path, state := range t.states {
+		files = append(files, FileStat{
+			Path:         t.displayPath(path),
+			AbsPath:      path,
+			Lines:        state.lineCount,
+			LastActivity: state.lastActivity,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+func (t *Tailer) SetFilters(include, exclude []string, regex bool) error {
+	includes, err := compilePatterns(include, regex)
+	if err != nil {
+		return err
+	}
+	excludes, err := compilePatterns(exclude, regex)
+	if err != nil {
+		return err
+	}
+	update := filterUpdate{
+		include:    include,
+		exclude:    exclude,
+		forceRegex: regex,
+		includes:   includes,
+		excludes:   excludes,
+	}
+	// Only the latest update matters, so a pending one is replaced and the
+	// loop is signalled without blocking: the caller may be the consumer the
+	// loop is blocked on with -overflow block.
+	t.mu.Lock()
+	t.filters = &update
+	t.mu.Unlock()
+	select {
+	case t.filterCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 func (t *Tailer) Start(ctxDone <-chan struct{}) error {
+	if err := t.checkSources(); err != nil {
+		return err
+	}
 	if !t.cfg.Recursive {
-		if err := t.addWatch(t.cfg.Root); err != nil {
-			return err
+		for _, src := range t.sourceList() {
+			if !src.dir {
+				continue
+			}
+			if err := t.addWatch(src.path); err != nil {
+				return err
+			}
 		}
 	}
-	if err := t.scanAndRegister(); err != nil {
-		return err
+	t.stop = ctxDone
+	if t.cfg.Overflow == OverflowBlock {
+		// The initial backlog can exceed the channel buffer, and nobody reads
+		// Lines() until Start returns, so a blocking send must not run here.
+		go func() {
+			t.initialScan()
+			t.loop(ctxDone)
+		}()
+		return nil
 	}
-
+	t.initialScan()
 	go t.loop(ctxDone)
 	return nil
 }
 
+// initialScan registers the sources checkSources accepted; anything that
+// fails after that is reported on Errors() in every overflow mode.
+func (t *Tailer) initialScan() {
+	if err := t.scanAndRegister(); err != nil {
+		t.sendErr(OpWalk, "", err)
+	}
+}
+
 func (t *Tailer) loop(ctxDone <-chan struct{}) {
 	defer func() {
-		_ = t.watcher.Close()
+		if t.watcher != nil {
+			_ = t.watcher.Close()
+		}
+		if t.poller != nil {
+			_ = t.poller.Close()
+		}
+		if err := t.saveCheckpoints(); err != nil {
+			t.sendErr(OpCheckpoint, t.cfg.CheckpointPath, err)
+		}
+		t.closeStates()
+		t.flushRecords(true)
+		t.flushReorder(true)
 		close(t.lines)
+		t.closeSubscribers()
 		close(t.errs)
 		close(t.done)
 	}()
@@ -222,29 +503,103 @@ func (t *Tailer) loop(ctxDone <-chan struct{}) {
 		ticker = time.NewTicker(t.cfg.ScanInterval)
 		defer ticker.Stop()
 	}
+	var checkpointTicker *time.Ticker
+	if t.cfg.CheckpointPath != "" {
+		checkpointTicker = time.NewTicker(t.cfg.CheckpointInterval)
+		defer checkpointTicker.Stop()
+	}
+	dropTicker := time.NewTicker(dropFlushInterval)
+	defer dropTicker.Stop()
+	var multilineTicker *time.Ticker
+	if t.multiline != nil {
+		multilineTicker = time.NewTicker(t.multiline.timeout / 2)
+		defer multilineTicker.Stop()
+	}
+	var reorderTicker *time.Ticker
+	if t.reorder != nil {
+		reorderTicker = time.NewTicker(t.reorder.window / 4)
+		defer reorderTicker.Stop()
+	}
+
+	// The initial backlog of every file is merged in one go.
+	t.flushRecords(true)
+	t.flushReorder(true)
 
 	for {
 		select {
 		case <-ctxDone:
 			return
-		case event, ok := <-t.watcher.Events:
+		case event, ok := <-watchEvents(t.watcher):
 			if !ok {
 				return
 			}
 			t.handleEvent(event)
-		case err, ok := <-t.watcher.Errors:
+		case err, ok := <-watchErrors(t.watcher):
 			if !ok {
 				return
 			}
-			t.sendErr(err)
+			t.sendErr(OpWatch, "", err)
+		case event := <-t.pollEvents():
+			t.handleEvent(event)
+		case err := <-t.pollErrors():
+			t.sendErr(OpWatch, "", err)
 		case <-t.tickChan(ticker):
 			if err := t.scanAndRegister(); err != nil {
-				t.sendErr(err)
+				t.sendErr(OpWalk, "", err)
+			}
+		case <-dropTicker.C:
+			t.flushDrops()
+			t.flushSubscriberDrops()
+		case <-t.tickChan(multilineTicker):
+			t.flushRecords(false)
+		case <-t.tickChan(reorderTicker):
+			t.flushReorder(false)
+		case <-t.filterCh:
+			t.mu.Lock()
+			update := t.filters
+			t.filters = nil
+			t.mu.Unlock()
+			if update == nil {
+				continue
+			}
+			if err := t.applyFilters(*update); err != nil {
+				t.sendErr(OpWalk, "", err)
+			}
+		case <-t.tickChan(checkpointTicker):
+			if err := t.saveCheckpoints(); err != nil {
+				t.sendErr(OpCheckpoint, t.cfg.CheckpointPath, err)
 			}
 		}
 	}
 }
 
+func (t *Tailer) applyFilters(update filterUpdate) error {
+	t.cfg.Include = update.include
+	t.cfg.Exclude = update.exclude
+	t.cfg.ForceRegex = update.forceRegex
+	t.includes = update.includes
+	t.excludes = update.excludes
+
+	t.mu.Lock()
+	var dropped []*fileState
+	for path, state := range t.states {
+		if !t.shouldInclude(path) {
+			dropped = append(dropped, state)
+			delete(t.states, path)
+		}
+	}
+	t.mu.Unlock()
+	// A dropped file's pending record is complete as far as it goes; its grep
+	// context ends with it.
+	for _, state := range dropped {
+		t.flushRecord(state)
+		state.grep = grepState{}
+		state.close()
+	}
+
+	return t.scanAndRegister()
+}
+
 func (t *Tailer) tickChan(ticker *time.Ticker) <-chan time.Time {
 	if ticker == nil {
 		return nil
@@ -268,6 +623,9 @@ func (t *Tailer) handleEvent(event fsnotify.Event) {
 }
 
 func (t *Tailer) handleCreate(path string) {
+	if !t.cfg.FollowSymlinks && isSymlink(path) {
+		return
+	}
 	info, err := os.Stat(path)
 	if err != nil {
 		return
@@ -276,15 +634,28 @@ func (t *Tailer) handleCreate(path string) {
 		if !t.cfg.Recursive {
 			return
 		}
+		if src, _ := t.sourceFor(path); src == nil || !src.dir {
+			return
+		}
+		if t.cfg.FollowSymlinks && !t.followDir(path, info) {
+			return
+		}
 		if err := t.addWatch(path); err != nil {
-			t.sendErr(err)
+			t.sendErr(OpWatch, path, err)
 		}
 		_ = t.scanDir(path)
 		return
 	}
-	if info.Mode().IsRegular() {
-		t.ensureFile(path)
+	if !info.Mode().IsRegular() {
+		return
+	}
+	if state := t.getState(path); state != nil {
+		if err := t.readNew(path, state); err != nil {
+			t.sendErr(OpRead, path, err)
+		}
+		return
 	}
+	t.ensureFile(path)
 }
 
 func (t *Tailer) handleWrite(path string) {
@@ -301,65 +672,36 @@ func (t *Tailer) handleWrite(path string) {
 			t.removePath(path)
 			return
 		}
-		t.sendErr(err)
+		t.sendErr(OpRead, path, err)
 	}
 }
 
 func (t *Tailer) scanAndRegister() error {
-	if !t.cfg.Recursive {
-		return t.scanRoot()
-	}
 	seenFiles := make(map[string]struct{})
 	seenDirs := make(map[string]struct{})
-	walkErr := filepath.WalkDir(t.cfg.Root, func(path string, entry os.DirEntry, err error) error {
-		if err != nil {
-			t.sendErr(err)
-			return nil
-		}
-		if entry.Type()&os.ModeSymlink != 0 {
-			if entry.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if entry.IsDir() {
-			seenDirs[path] = struct{}{}
-			if err := t.addWatch(path); err != nil {
-				t.sendErr(err)
-			}
-			return nil
+	var scanErr error
+	for _, src := range t.sourceList() {
+		var err error
+		switch {
+		case !src.dir:
+			t.scanFile(src.path, seenFiles, seenDirs)
+		case t.cfg.Recursive:
+			err = t.walkRoot(src.path, seenFiles, seenDirs)
+		default:
+			err = t.scanRoot(src.path, seenFiles, seenDirs)
 		}
-		if !entry.Type().IsRegular() {
-			return nil
+		if err != nil && scanErr == nil {
+			scanErr = err
 		}
-		if !t.shouldInclude(path) {
-			return nil
-		}
-		if state := t.getState(path); state != nil {
-			if err := t.readNew(path, state); err != nil {
-				t.sendErr(err)
-			}
-			seenFiles[path] = struct{}{}
-			return nil
-		}
-		isText, err := t.isTextFile(path)
-		if err != nil {
-			t.sendErr(err)
-			return nil
-		}
-		if isText {
-			t.ensureFile(path)
-			seenFiles[path] = struct{}{}
-		}
-		return nil
-	})
+	}
 
 	t.mu.Lock()
-	for path := range t.states {
+	for path, state := range t.states {
 		if _, ok := seenFiles[path]; !ok {
 			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
 				continue
 			}
+			state.close()
 			delete(t.states, path)
 		}
 	}
@@ -370,26 +712,59 @@ func (t *Tailer) scanAndRegister() error {
 			if info, err := os.Stat(path); err == nil && info.IsDir() {
 				continue
 			}
-			_ = t.watcher.Remove(path)
-			delete(t.watchedDir, path)
+			t.removeWatch(path)
 		}
 	}
+	// A removed file that was not recreated by now starts afresh if it returns.
+	clear(t.removed)
 
-	return walkErr
+	return scanErr
 }
 
-func (t *Tailer) scanRoot() error {
-	seenFiles := make(map[string]struct{})
-	root := t.cfg.Root
+func (t *Tailer) walkRoot(root string, seenFiles, seenDirs map[string]struct{}) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			t.sendErr(OpWalk, path, err)
+			return nil
+		}
+		// Followed directory links are walked as "link/", which WalkDir resolves.
+		path = filepath.Clean(path)
+		if entry.Type()&os.ModeSymlink != 0 {
+			return t.walkLink(path, seenFiles, seenDirs)
+		}
+		if entry.IsDir() {
+			if t.cfg.FollowSymlinks {
+				info, err := entry.Info()
+				if err != nil || !t.followDir(path, info) {
+					return filepath.SkipDir
+				}
+			}
+			seenDirs[path] = struct{}{}
+			if err := t.addWatch(path); err != nil {
+				t.sendErr(OpWatch, path, err)
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		t.scanEntry(path, seenFiles)
+		return nil
+	})
+}
+
+func (t *Tailer) scanRoot(root string, seenFiles, seenDirs map[string]struct{}) error {
 	entries, err := os.ReadDir(root)
 	if err != nil {
 		return err
 	}
+	seenDirs[root] = struct{}{}
 
 	for _, entry := range entries {
 		if entry.Type()&os.ModeSymlink != 0 {
-			if entry.IsDir() {
-				continue
+			path := filepath.Join(root, entry.Name())
+			if info, err := os.Stat(path); err == nil && t.cfg.FollowSymlinks && info.Mode().IsRegular() {
+				t.scanEntry(path, seenFiles)
 			}
 			continue
 		}
@@ -399,103 +774,51 @@ func (t *Tailer) scanRoot() error {
 		if !entry.Type().IsRegular() {
 			continue
 		}
-		path := filepath.Join(root, entry.Name())
-		if !t.shouldInclude(path) {
-			continue
-		}
-		if state := t.getState(path); state != nil {
-			if err := t.readNew(path, state); err != nil {
-				t.sendErr(err)
-			}
-			seenFiles[path] = struct{}{}
-			continue
-		}
-		isText, err := t.isTextFile(path)
-		if err != nil {
-			t.sendErr(err)
-			continue
-		}
-		if isText {
-			t.ensureFile(path)
-			seenFiles[path] = struct{}{}
-		}
-	}
-
-	t.mu.Lock()
-	for path := range t.states {
-		if _, ok := seenFiles[path]; !ok {
-			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
-				continue
-			}
-			delete(t.states, path)
-		}
+		t.scanEntry(filepath.Join(root, entry.Name()), seenFiles)
 	}
-	t.mu.Unlock()
-
 	return nil
 }
 
-func (t *Tailer) scanDir(root string) error {
-	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
-		if err != nil {
-			t.sendErr(err)
-			return nil
-		}
-		if entry.Type()&os.ModeSymlink != 0 {
-			if entry.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if entry.IsDir() {
-			if err := t.addWatch(path); err != nil {
-				t.sendErr(err)
-			}
-			return nil
-		}
-		if !entry.Type().IsRegular() {
-			return nil
-		}
-		if !t.shouldInclude(path) {
-			return nil
-		}
-		if state := t.getState(path); state != nil {
-			if err := t.readNew(path, state); err != nil {
-				t.sendErr(err)
-			}
-			return nil
-		}
-		isText, err := t.isTextFile(path)
-		if err != nil {
-			t.sendErr(err)
-			return nil
-		}
-		if isText {
-			t.ensureFile(path)
+func (t *Tailer) scanEntry(path string, seenFiles map[string]struct{}) {
+	if !t.shouldInclude(path) {
+		return
+	}
+	if state := t.getState(path); state != nil {
+		if err := t.readNew(path, state); err != nil {
+			t.sendErr(OpRead, path, err)
 		}
-		return nil
-	})
-}
-
-func (t *Tailer) addWatch(path string) error {
-	if _, ok := t.watchedDir[path]; ok {
-		return nil
+		seenFiles[path] = struct{}{}
+		return
 	}
-	if err := t.watcher.Add(path); err != nil {
-		return err
+	isText, err := t.isTextFile(path)
+	if err != nil {
+		t.sendErr(OpTextDetect, path, err)
+		return
+	}
+	if isText {
+		t.ensureFile(path)
+		seenFiles[path] = struct{}{}
 	}
-	t.watchedDir[path] = struct{}{}
-	return nil
+}
+
+// scanDir picks up a directory that appeared after the initial scan.
+func (t *Tailer) scanDir(root string) error {
+	return t.walkRoot(root, make(map[string]struct{}), make(map[string]struct{}))
 }
 
 func (t *Tailer) removePath(path string) {
 	if _, ok := t.watchedDir[path]; ok {
-		_ = t.watcher.Remove(path)
-		delete(t.watchedDir, path)
+		t.removeWatch(path)
 		prefix := path + string(os.PathSeparator)
+		for dir := range t.watchedDir {
+			if strings.HasPrefix(dir, prefix) {
+				t.removeWatch(dir)
+			}
+		}
 		t.mu.Lock()
-		for filePath := range t.states {
+		for filePath, state := range t.states {
 			if strings.HasPrefix(filePath, prefix) {
+				state.close()
 				delete(t.states, filePath)
 			}
 		}
@@ -503,17 +826,30 @@ func (t *Tailer) removePath(path string) {
 		return
 	}
 
+	state := t.getState(path)
+	if state == nil {
+		return
+	}
+	t.drain(path, state)
+	t.flushRecord(state)
 	t.mu.Lock()
 	delete(t.states, path)
 	t.mu.Unlock()
+	// Remember the path so a file recreated there is reported as a rotation.
+	t.removed[path] = struct{}{}
 }
 
 func (t *Tailer) ensureFile(path string) {
 	if !t.shouldInclude(path) {
 		return
 	}
-	isText, err := t.isTextFile(path)
-	if err != nil || !isText {
+	if !t.isExplicitFile(path) {
+		isText, err := t.isTextFile(path)
+		if err != nil || !isText {
+			return
+		}
+	}
+	if t.cfg.FollowSymlinks && t.duplicateFile(path) {
 		return
 	}
 
@@ -526,36 +862,64 @@ func (t *Tailer) ensureFile(path string) {
 	t.states[path] = state
 	t.mu.Unlock()
 
+	if _, ok := t.removed[path]; ok {
+		delete(t.removed, path)
+		if err := t.rotate(path, state); err != nil {
+			t.sendErr(OpRead, path, err)
+		}
+		return
+	}
 	if err := t.initFile(path, state); err != nil {
-		t.sendErr(err)
+		t.sendErr(OpRead, path, err)
 	}
 }
 
 func (t *Tailer) initFile(path string, state *fileState) error {
+	state.enc = t.encodingFor(path)
+	if t.cfg.Resume {
+		if offset, ok := t.resumeOffset(path, state); ok {
+			return t.readFromOffset(path, state, offset, false)
+		}
+	}
 	if t.cfg.FromStart {
 		return t.readFromOffset(path, state, 0, false)
 	}
 
 	if t.cfg.N > 0 {
-		lines, partial, err := tailLastLines(path, t.cfg.N)
+		lines, partial, offsets, err := readLastLines(path, t.cfg.N, state.enc)
 		if err != nil {
 			return err
 		}
-		for _, line := range lines {
-			t.sendLine(Line{Path: t.displayPath(path), Text: line})
+		if have := len(lines) + min(len(partial), 1); t.cfg.RotatedBacklog && have < t.cfg.N {
+			t.emitRotatedBacklog(path, state, t.cfg.N-have)
+		}
+		for i, line := range lines {
+			t.emit(state, path, Line{Path: t.displayPath(path), Text: line, Offset: offsets[i]})
 		}
 		if len(partial) > 0 {
 			state.partial = partial
 			state.partialDisplayed = true
-			t.sendLine(Line{Path: t.displayPath(path), Text: string(partial), Partial: true})
+			state.lineStart = offsets[len(lines)]
+			text, _ := state.enc.text(partial, 0, state.lineStart == 0)
+			t.emit(state, path, Line{Path: t.displayPath(path), Text: text, Offset: state.lineStart, Partial: true})
 		}
 	}
 
-	info, err := os.Stat(path)
+	file, owned, err := t.openFile(path, state)
+	if err != nil {
+		return err
+	}
+	if owned {
+		defer file.Close()
+	}
+	info, err := file.Stat()
 	if err != nil {
 		return err
 	}
 	state.offset = info.Size()
+	if len(state.partial) == 0 {
+		state.lineStart = state.offset
+	}
 	return nil
 }
 
@@ -568,6 +932,10 @@ func (t *Tailer) readNew(path string, state *fileState) error {
 		return nil
 	}
 
+	if id, ok := fileIdentity(info); ok && state.hasID && id != state.id {
+		return t.rotate(path, state)
+	}
+
 	if info.Size() == state.offset {
 		return nil
 	}
@@ -581,13 +949,65 @@ func (t *Tailer) readNew(path string, state *fileState) error {
 	return t.readFromOffset(path, state, state.offset, true)
 }
 
-func (t *Tailer) readFromOffset(path string, state *fileState, offset int64, includeExistingPartial bool) error {
+func (t *Tailer) rotate(path string, state *fileState) error {
+	t.drain(path, state)
+	pathDisplay := t.displayPath(path)
+	t.emit(state, path, Line{Path: pathDisplay, Text: fmt.Sprintf("[ft: %s rotated]", pathDisplay), Marker: true})
+	state.reset()
+	state.enc = t.encodingFor(path)
+	return t.readFromOffset(path, state, 0, false)
+}
+
+func (t *Tailer) drain(path string, state *fileState) {
+	if state.file != nil {
+		if err := t.readFile(state.file, path, state, state.offset, true); err != nil {
+			t.sendErr(OpRead, path, err)
+		}
+	}
+	if len(state.partial) > 0 {
+		text, _ := state.enc.text(state.partial, t.cfg.MaxLineBytes, state.lineStart == 0)
+		t.emit(state, path, Line{Path: t.displayPath(path), Text: text, Offset: state.lineStart, LineNo: state.nextLineNo(), Update: state.partialDisplayed})
+	}
+	state.partial = nil
+	state.partialDisplayed = false
+	state.close()
+}
+
+func (t *Tailer) openFile(path string, state *fileState) (*os.File, bool, error) {
+	if state.file != nil {
+		return state.file, false, nil
+	}
 	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, false, err
+	}
+	id, ok := fileIdentity(info)
+	if !ok {
+		return file, true, nil
+	}
+	state.file = file
+	state.id = id
+	state.hasID = true
+	return file, false, nil
+}
+
+func (t *Tailer) readFromOffset(path string, state *fileState, offset int64, includeExistingPartial bool) error {
+	file, owned, err := t.openFile(path, state)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	if owned {
+		defer file.Close()
+	}
+	return t.readFile(file, path, state, offset, includeExistingPartial)
+}
 
+func (t *Tailer) readFile(file *os.File, path string, state *fileState, offset int64, includeExistingPartial bool) error {
 	if _, err := file.Seek(offset, io.SeekStart); err != nil {
 		return err
 	}
@@ -599,42 +1019,58 @@ func (t *Tailer) readFromOffset(path string, state *fileState, offset int64, inc
 	maxBytes := t.cfg.MaxLineBytes
 	if includeExistingPartial && len(state.partial) > 0 {
 		carry = append(carry, state.partial...)
+	} else {
+		state.lineStart = offset
+		if offset == 0 {
+			state.lineNo = 0
+			state.lineNoKnown = true
+		}
 	}
 
 	buf := make([]byte, readChunkSize)
 	var totalRead int64
+	width := state.enc.newlineWidth()
 	for {
 		n, err := file.Read(buf)
 		if n > 0 {
 			totalRead += int64(n)
 			data := buf[:n]
+			// A UTF-16 code unit can be split across writes; move the odd
+			// byte in front of the new data so newlines stay aligned.
+			if odd := len(carry) % width; odd != 0 {
+				data = append(carry[len(carry)-odd:len(carry):len(carry)], data...)
+				carry = carry[:len(carry)-odd]
+			}
 			for len(data) > 0 {
-				idx := bytes.IndexByte(data, '\n')
+				idx := state.enc.indexNewline(data)
 				if idx < 0 {
 					carry = append(carry, data...)
 					if maxBytes > 0 && len(carry) > maxBytes {
-						text, truncated := truncateLineBytes(carry, maxBytes)
+						text, truncated := state.enc.text(carry, maxBytes, state.lineStart == 0)
 						if truncated {
 							update := hadPartial && !updatedPartial
-							t.sendLine(Line{Path: pathDisplay, Text: text, Update: update})
+							t.emit(state, path, Line{Path: pathDisplay, Text: text, Offset: state.lineStart, LineNo: state.nextLineNo(), Update: update})
 							if update {
 								updatedPartial = true
 							}
-							carry = carry[:0]
+							odd := len(carry) % width
+							carry = append(carry[:0], carry[len(carry)-odd:]...)
+							state.lineStart = offset + totalRead - int64(odd)
 						}
 					}
 					break
 				}
 				lineBytes := append(carry, data[:idx]...)
 				carry = carry[:0]
-				lineBytes = trimTrailingCR(lineBytes)
-				text, _ := truncateLineBytes(lineBytes, maxBytes)
+				text, _ := state.enc.text(lineBytes, maxBytes, state.lineStart == 0)
 				update := hadPartial && !updatedPartial
-				t.sendLine(Line{Path: pathDisplay, Text: text, Update: update})
+				t.emit(state, path, Line{Path: pathDisplay, Text: text, Offset: state.lineStart, LineNo: state.nextLineNo(), Update: update})
 				if update {
 					updatedPartial = true
 				}
-				data = data[idx+1:]
+				state.lineStart = offset + totalRead - int64(len(data)) + int64(idx+width)
+				state.lineNo++
+				data = data[idx+width:]
 			}
 		}
 		if err != nil {
@@ -646,10 +1082,10 @@ func (t *Tailer) readFromOffset(path string, state *fileState, offset int64, inc
 	}
 
 	if len(carry) > 0 {
-		partial := trimTrailingCR(carry)
-		text, truncated := truncateLineBytes(partial, maxBytes)
+		partial := state.enc.trimCR(carry)
+		text, truncated := state.enc.text(partial[:len(partial)-len(partial)%width], maxBytes, state.lineStart == 0)
 		update := hadPartial && !updatedPartial
-		t.sendLine(Line{Path: pathDisplay, Text: text, Partial: !truncated, Update: update})
+		t.emit(state, path, Line{Path: pathDisplay, Text: text, Offset: state.lineStart, LineNo: state.nextLineNo(), Partial: !truncated, Update: update})
 		if update {
 			updatedPartial = true
 		}
@@ -666,6 +1102,9 @@ func (t *Tailer) readFromOffset(path string, state *fileState, offset int64, inc
 	}
 
 	state.offset = offset + totalRead
+	if state.partial == nil {
+		state.lineStart = state.offset
+	}
 	return nil
 }
 
@@ -679,23 +1118,32 @@ func (t *Tailer) displayPath(path string) string {
 	if t.cfg.Absolute {
 		return path
 	}
-	rel, err := filepath.Rel(t.cfg.Root, path)
-	if err != nil {
+	src, rel := t.sourceFor(path)
+	switch {
+	case src == nil:
 		return path
+	case !src.dir:
+		return src.label
+	case src.label != "":
+		return filepath.Join(src.label, rel)
+	default:
+		return rel
 	}
-	return rel
 }
 
 func (t *Tailer) shouldInclude(path string) bool {
+	src, rel := t.sourceFor(path)
+	if src == nil {
+		return false
+	}
+	if !src.dir {
+		return true
+	}
 	if len(t.includes) == 0 && len(t.excludes) == 0 {
 		return true
 	}
 
 	name := filepath.Base(path)
-	rel, err := filepath.Rel(t.cfg.Root, path)
-	if err != nil {
-		rel = path
-	}
 
 	if len(t.includes) > 0 {
 		matched := false
@@ -729,29 +1177,6 @@ func (t *Tailer) shouldInclude(path string) bool {
 	return true
 }
 
-func (t *Tailer) sendLine(line Line) {
-	select {
-	case t.lines <- line:
-		return
-	default:
-	}
-	select {
-	case <-t.lines:
-	default:
-	}
-	select {
-	case t.lines <- line:
-	default:
-	}
-}
-
-func (t *Tailer) sendErr(err error) {
-	select {
-	case t.errs <- err:
-	default:
-	}
-}
-
 func splitLines(data []byte) ([]string, []byte) {
 	if len(data) == 0 {
 		return nil, nil
@@ -777,22 +1202,28 @@ func splitLines(data []byte) ([]string, []byte) {
 	return lines, nil
 }
 
-func tailLastLines(path string, n int) ([]string, []byte, error) {
+func tailLastLines(path string, n int) ([]string, []byte, []int64, error) {
+	return readLastLines(path, n, nil)
+}
+
+// readLastLines returns the last n lines decoded from enc, the raw trailing
+// partial line, and the offset of each line plus that of the partial.
+func readLastLines(path string, n int, enc *textEncoding) ([]string, []byte, []int64, error) {
 	if n <= 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	defer file.Close()
 
 	info, err := file.Stat()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	if info.Size() == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 
 	var (
@@ -811,10 +1242,10 @@ func tailLastLines(path string, n int) ([]string, []byte, error) {
 		buf := make([]byte, readSize)
 		_, err := file.ReadAt(buf, remaining)
 		if err != nil && !errors.Is(err, io.EOF) {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		chunks = append(chunks, buf)
-		lineCount += bytes.Count(buf, []byte("\n"))
+		lineCount += enc.countNewlines(buf)
 	}
 
 	data := make([]byte, 0, 0)
@@ -822,17 +1253,31 @@ func tailLastLines(path string, n int) ([]string, []byte, error) {
 		data = append(data, chunks[i]...)
 	}
 
-	lines, partial := splitLines(data)
-	if len(partial) > 0 {
-		if len(lines) > n-1 {
-			lines = lines[len(lines)-(n-1):]
+	offsets := make([]int64, 1, lineCount+1)
+	offsets[0] = remaining
+	for rest := data; ; {
+		idx := enc.indexNewline(rest)
+		if idx < 0 {
+			break
 		}
-		return lines, partial, nil
+		rest = rest[idx+enc.newlineWidth():]
+		offsets = append(offsets, remaining+int64(len(data)-len(rest)))
+	}
+
+	lines, partial := enc.splitLines(data)
+	if remaining == 0 && len(lines) > 0 {
+		lines[0] = strings.TrimPrefix(lines[0], "\uFEFF")
+	}
+	keep := n
+	if len(partial) > 0 {
+		keep = n - 1
 	}
-	if len(lines) > n {
-		lines = lines[len(lines)-n:]
+	if len(lines) > keep {
+		drop := len(lines) - keep
+		lines = lines[drop:]
+		offsets = offsets[drop:]
 	}
-	return lines, nil, nil
+	return lines, partial, offsets, nil
 }
 
 func trimTrailingCR(data []byte) []byte {
@@ -846,24 +1291,36 @@ func trimTrailingCR(data []byte) []byte {
 }
 
 func isTextFile(path string) (bool, error) {
-	file, err := os.Open(path)
+	sample, err := readSample(path, defaultSampleSize)
 	if err != nil {
 		return false, err
 	}
+	return isTextData(sample), nil
+}
+
+func readSample(path string, size int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
-	buf := make([]byte, defaultSampleSize)
-	n, err := file.Read(buf)
-	if err != nil && !errors.Is(err, io.EOF) {
-		return false, err
+	buf := make([]byte, size)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
 	}
-	return isTextData(buf[:n]), nil
+	return buf[:n], nil
 }
 
 func isTextData(data []byte) bool {
 	if len(data) == 0 {
 		return true
 	}
+	// UTF-16 text is full of NULs; judge it by its decoded form instead.
+	if enc := detectUTF16(data); enc != nil {
+		return isMostlyTextUTF8([]byte(enc.decode(data)))
+	}
 	if bytes.IndexByte(data, 0) >= 0 {
 		return false
 	}
@@ -1,10 +1,22 @@
 package tailer
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 func TestSplitLines(t *testing.T) {
@@ -85,7 +97,7 @@ func TestTailLastLines(t *testing.T) {
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	lines, partial, err := tailLastLines(path, 2)
+	lines, partial, offsets, err := tailLastLines(path, 2)
 	if err != nil {
 		t.Fatalf("tailLastLines: %v", err)
 	}
@@ -95,12 +107,15 @@ func TestTailLastLines(t *testing.T) {
 	if len(lines) != 2 || lines[0] != "three" || lines[1] != "four" {
 		t.Fatalf("unexpected lines: %#v", lines)
 	}
+	if len(offsets) != 3 || offsets[0] != 8 || offsets[1] != 14 || offsets[2] != 19 {
+		t.Fatalf("unexpected offsets: %#v", offsets)
+	}
 
 	content = "one\ntwo\nthree"
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	lines, partial, err = tailLastLines(path, 2)
+	lines, partial, offsets, err = tailLastLines(path, 2)
 	if err != nil {
 		t.Fatalf("tailLastLines: %v", err)
 	}
@@ -110,6 +125,9 @@ func TestTailLastLines(t *testing.T) {
 	if len(lines) != 1 || lines[0] != "two" {
 		t.Fatalf("unexpected lines: %#v", lines)
 	}
+	if len(offsets) != 2 || offsets[0] != 4 || offsets[1] != 8 {
+		t.Fatalf("unexpected offsets: %#v", offsets)
+	}
 }
 
 func TestReadFromOffsetPartialUpdate(t *testing.T) {
@@ -389,6 +407,7 @@ func newTestTailer(root string, include, exclude []string, forceRegex bool) *Tai
 		cfg:      Config{Root: root, Include: include, Exclude: exclude, ForceRegex: forceRegex, MaxLineBytes: defaultMaxLine},
 		includes: includes,
 		excludes: excludes,
+		rotErrs:  make(map[string]struct{}),
 	}
 }
 
@@ -453,3 +472,1535 @@ func TestNonRecursiveScan(t *testing.T) {
 		t.Fatalf("expected subdir file to be excluded in non-recursive mode")
 	}
 }
+
+func TestReadNewAfterRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file identity is not tracked on windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer := &Tailer{
+		cfg:   Config{Root: dir, Absolute: true},
+		lines: make(chan Line, 10),
+	}
+	state := &fileState{}
+	defer state.close()
+
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	if line := <-tailer.lines; line.Text != "one" {
+		t.Fatalf("unexpected line: %#v", line)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	if _, err := file.WriteString("two\nlast"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("three\nfour\nfive\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if err := tailer.readNew(path, state); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+
+	want := []Line{
+		{Path: path, AbsPath: path, Text: "two", Offset: 4, LineNo: 2},
+		{Path: path, AbsPath: path, Text: "last", Offset: 8, LineNo: 3, Partial: true},
+		{Path: path, AbsPath: path, Text: "last", Offset: 8, LineNo: 3, Update: true},
+		{Path: path, AbsPath: path, Text: "[ft: " + path + " rotated]", Marker: true},
+		{Path: path, AbsPath: path, Text: "three", Offset: 0, LineNo: 1},
+		{Path: path, AbsPath: path, Text: "four", Offset: 6, LineNo: 2},
+		{Path: path, AbsPath: path, Text: "five", Offset: 11, LineNo: 3},
+	}
+	for _, expected := range want {
+		got := <-tailer.lines
+		if got.Time.IsZero() {
+			t.Fatalf("expected receive time on %#v", got)
+		}
+		got.Time = time.Time{}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, got)
+		}
+	}
+}
+
+func TestRemovePathReleasesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer := &Tailer{
+		cfg:        Config{Root: dir, Absolute: true},
+		lines:      make(chan Line, 10),
+		states:     make(map[string]*fileState),
+		watchedDir: make(map[string]watcher),
+		removed:    make(map[string]struct{}),
+	}
+	state := &fileState{}
+	tailer.states[path] = state
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	<-tailer.lines
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	tailer.removePath(path)
+	if state.file != nil || tailer.getState(path) != nil {
+		t.Fatalf("expected the state to be closed and dropped, got %#v", state)
+	}
+
+	if err := os.WriteFile(path, []byte("new\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	tailer.handleCreate(path)
+	defer tailer.getState(path).close()
+	if line := <-tailer.lines; !line.Marker {
+		t.Fatalf("expected rotation marker, got %#v", line)
+	}
+	if line := <-tailer.lines; line.Text != "new" {
+		t.Fatalf("unexpected line: %#v", line)
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app.log")
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("one\ntwo\npart"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	first, err := New(Config{Root: root, N: 10, CheckpointPath: statePath})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	first.lines = make(chan Line, 10)
+	if err := first.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	if err := first.saveCheckpoints(); err != nil {
+		t.Fatalf("saveCheckpoints: %v", err)
+	}
+	first.watcher.Close()
+	first.getState(path).close()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	if _, err := file.WriteString("ial\nthree\n"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	second, err := New(Config{Root: root, N: 10, CheckpointPath: statePath, Resume: true})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer second.watcher.Close()
+	second.lines = make(chan Line, 10)
+	if err := second.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	defer second.getState(path).close()
+
+	close(second.lines)
+	var got []string
+	for line := range second.lines {
+		got = append(got, line.Text)
+	}
+	if len(got) != 2 || got[0] != "partial" || got[1] != "three" {
+		t.Fatalf("unexpected resumed lines: %#v", got)
+	}
+}
+
+func TestCheckpointPrunesStaleEntries(t *testing.T) {
+	root := t.TempDir()
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	old := time.Now().Add(-checkpointRetention - time.Hour)
+	if err := writeCheckpoints(statePath, map[string]checkpoint{
+		"/gone/old.log":     {Offset: 1, Updated: old},
+		"/other/recent.log": {Offset: 2, Updated: time.Now()},
+	}); err != nil {
+		t.Fatalf("writeCheckpoints: %v", err)
+	}
+
+	tailer, err := New(Config{Root: root, CheckpointPath: statePath, Watch: WatchPoll})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	if err := tailer.saveCheckpoints(); err != nil {
+		t.Fatalf("saveCheckpoints: %v", err)
+	}
+	saved, err := loadCheckpoints(statePath)
+	if err != nil {
+		t.Fatalf("loadCheckpoints: %v", err)
+	}
+	if _, ok := saved["/gone/old.log"]; ok {
+		t.Fatalf("expected the stale entry to be pruned")
+	}
+	if _, ok := saved["/other/recent.log"]; !ok {
+		t.Fatalf("expected the recent entry to be kept")
+	}
+}
+
+func TestCheckpointResumeFingerprintMismatch(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app.log")
+	if err := os.WriteFile(path, []byte("replaced\ncontent\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer := newTestTailer(root, nil, nil, false)
+	tailer.saved = map[string]checkpoint{
+		path: {Fingerprint: "deadbeef", FingerprintLen: 4, Offset: 9},
+	}
+	state := &fileState{}
+	defer state.close()
+	if _, ok := tailer.resumeOffset(path, state); ok {
+		t.Fatalf("expected fingerprint mismatch to disable resume")
+	}
+}
+
+func TestLineFilterContext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	content := "a\nb\nERROR one\nc\nd\ne\nf\nerror two\ng\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	grep, err := compileLineFilter(Config{Grep: []string{"error"}, GrepIgnoreCase: true, GrepBefore: 1, GrepAfter: 1})
+	if err != nil {
+		t.Fatalf("compileLineFilter: %v", err)
+	}
+	tailer := &Tailer{
+		cfg:   Config{Root: dir, Absolute: true},
+		lines: make(chan Line, 20),
+		grep:  grep,
+	}
+	state := &fileState{}
+	defer state.close()
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	close(tailer.lines)
+
+	var got []string
+	for line := range tailer.lines {
+		got = append(got, line.Text)
+	}
+	want := []string{"b", "ERROR one", "c", "--", "f", "error two", "g"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLineFilterPartialCompletion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("keep"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	grep, err := compileLineFilter(Config{Grep: []string{"keep"}, GrepExclude: []string{"drop"}, GrepFixed: true})
+	if err != nil {
+		t.Fatalf("compileLineFilter: %v", err)
+	}
+	tailer := &Tailer{
+		cfg:   Config{Root: dir, Absolute: true},
+		lines: make(chan Line, 10),
+		grep:  grep,
+	}
+	state := &fileState{}
+	defer state.close()
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	if len(tailer.lines) != 0 {
+		t.Fatalf("expected partial line to be held back")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	if _, err := file.WriteString(" but drop\nkeep me\n"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := tailer.readFromOffset(path, state, state.offset, true); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+
+	line := <-tailer.lines
+	if line.Text != "keep me" || line.Update || line.Partial {
+		t.Fatalf("unexpected line: %#v", line)
+	}
+	if len(tailer.lines) != 0 {
+		t.Fatalf("expected no further lines")
+	}
+}
+
+func TestFilesReportsActivity(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "b.log"), []byte("one\ntwo\nthree"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.log"), nil, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer, err := New(Config{Root: root, FromStart: true})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer tailer.watcher.Close()
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+
+	files := tailer.Files()
+	if len(files) != 2 || files[0].Path != "a.log" || files[1].Path != "b.log" {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+	if files[0].Lines != 0 || !files[0].LastActivity.IsZero() {
+		t.Fatalf("expected no activity for a.log: %#v", files[0])
+	}
+	if files[1].Lines != 2 || files[1].LastActivity.IsZero() {
+		t.Fatalf("expected two complete lines for b.log: %#v", files[1])
+	}
+}
+
+func TestApplyFilters(t *testing.T) {
+	root := t.TempDir()
+	appLog := filepath.Join(root, "app.log")
+	appTxt := filepath.Join(root, "app.txt")
+	if err := os.WriteFile(appLog, []byte("log line\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.WriteFile(appTxt, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer, err := New(Config{Root: root, N: 2, Include: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer tailer.watcher.Close()
+	tailer.lines = make(chan Line, 10)
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	if line := <-tailer.lines; line.Text != "log line" {
+		t.Fatalf("unexpected line: %#v", line)
+	}
+
+	includes, err := compilePatterns([]string{"*.txt"}, false)
+	if err != nil {
+		t.Fatalf("compilePatterns: %v", err)
+	}
+	if err := tailer.applyFilters(filterUpdate{include: []string{"*.txt"}, includes: includes}); err != nil {
+		t.Fatalf("applyFilters: %v", err)
+	}
+	if tailer.getState(appLog) != nil {
+		t.Fatalf("expected app.log to be dropped")
+	}
+	if tailer.getState(appTxt) == nil {
+		t.Fatalf("expected app.txt to be tracked")
+	}
+	for _, want := range []string{"two", "three"} {
+		if line := <-tailer.lines; line.Text != want {
+			t.Fatalf("expected %q, got %#v", want, line)
+		}
+	}
+}
+
+func TestApplyFiltersFlushesDroppedRecord(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app.log")
+	if err := os.WriteFile(path, []byte("ERROR boom\n  at a()\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer, err := New(Config{Root: root, FromStart: true, MultilineIndent: true, Grep: []string{"boom"}, GrepBefore: 1})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer tailer.watcher.Close()
+	tailer.lines = make(chan Line, 10)
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	state := tailer.getState(path)
+	if len(tailer.lines) != 0 || state.record == nil {
+		t.Fatalf("expected the record to be pending, got %d lines", len(tailer.lines))
+	}
+
+	includes, err := compilePatterns([]string{"*.txt"}, false)
+	if err != nil {
+		t.Fatalf("compilePatterns: %v", err)
+	}
+	if err := tailer.applyFilters(filterUpdate{include: []string{"*.txt"}, includes: includes}); err != nil {
+		t.Fatalf("applyFilters: %v", err)
+	}
+	if tailer.getState(path) != nil {
+		t.Fatalf("expected app.log to be dropped")
+	}
+	if len(tailer.lines) != 1 {
+		t.Fatalf("expected the pending record to be flushed, got %d lines", len(tailer.lines))
+	}
+	if line := <-tailer.lines; line.Text != "ERROR boom\n  at a()" {
+		t.Fatalf("unexpected line: %#v", line)
+	}
+	if state.record != nil || state.grep.before != nil || state.grep.emitted {
+		t.Fatalf("expected the dropped state to be cleared, got %#v", state.grep)
+	}
+}
+
+func TestMultipleSources(t *testing.T) {
+	base := t.TempDir()
+	nginx := filepath.Join(base, "nginx")
+	app := filepath.Join(base, "app")
+	for _, dir := range []string{nginx, app} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	debug := filepath.Join(base, "debug.bin")
+	files := map[string]string{
+		filepath.Join(nginx, "access.log"): "GET /\n",
+		filepath.Join(app, "app.log"):      "started\n",
+		filepath.Join(base, "other.log"):   "ignored\n",
+		debug:                              "debug\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	tailer, err := New(Config{
+		Sources: []Source{{Path: nginx, Label: "web"}, {Path: app}, {Path: debug, Label: "debug"}},
+		N:       10,
+	})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer tailer.watcher.Close()
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+
+	var paths []string
+	for _, file := range tailer.Files() {
+		paths = append(paths, file.Path)
+	}
+	want := []string{filepath.Join(app, "app.log"), "debug", filepath.Join("web", "access.log")}
+	if strings.Join(paths, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, paths)
+	}
+
+	if tailer.shouldInclude(filepath.Join(base, "other.log")) {
+		t.Fatalf("expected file outside all sources to be excluded")
+	}
+}
+
+func TestOverflowDropOldestMarker(t *testing.T) {
+	tailer := &Tailer{
+		cfg:   Config{Overflow: OverflowDropOldest},
+		lines: make(chan Line, 4),
+	}
+	for i := 0; i < 6; i++ {
+		tailer.sendLine(Line{Path: "app.log", Text: string(rune('a' + i))})
+	}
+	if stats := tailer.Stats(); stats.Dropped != 2 || stats.DroppedByPath["app.log"] != 2 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+
+	<-tailer.lines
+	<-tailer.lines
+	tailer.sendLine(Line{Path: "app.log", Text: "g"})
+
+	var got []string
+	for len(tailer.lines) > 0 {
+		got = append(got, (<-tailer.lines).Text)
+	}
+	want := []string{"e", "f", "[ft: 2 lines dropped from app.log]", "g"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	tailer := &Tailer{
+		cfg:   Config{Overflow: OverflowDropNewest},
+		lines: make(chan Line, 2),
+	}
+	for _, text := range []string{"a", "b", "c"} {
+		tailer.sendLine(Line{Path: "app.log", Text: text})
+	}
+	if first := <-tailer.lines; first.Text != "a" {
+		t.Fatalf("expected oldest line to be kept, got %#v", first)
+	}
+	if stats := tailer.Stats(); stats.Dropped != 1 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+}
+
+func TestErrorHistory(t *testing.T) {
+	tailer := &Tailer{errs: make(chan *Error, 1)}
+	missing := filepath.Join(t.TempDir(), "missing.log")
+	_, statErr := os.Stat(missing)
+	tailer.sendErr(OpRead, missing, statErr)
+
+	first := <-tailer.errs
+	if first.Op != OpStat || first.Severity != SeverityWarning || first.Path != missing {
+		t.Fatalf("unexpected error: %#v", first)
+	}
+	if !errors.Is(first, os.ErrNotExist) {
+		t.Fatalf("expected wrapped not-exist error, got %v", first)
+	}
+
+	for i := 0; i < errorHistorySize+10; i++ {
+		tailer.sendErr(OpWatch, fmt.Sprintf("dir%d", i), errors.New("no space left on device"))
+	}
+	history := tailer.ErrorHistory()
+	if len(history) != errorHistorySize {
+		t.Fatalf("expected %d entries, got %d", errorHistorySize, len(history))
+	}
+	if history[0].Path != "dir10" || history[len(history)-1].Path != fmt.Sprintf("dir%d", errorHistorySize+9) {
+		t.Fatalf("unexpected history order: %s .. %s", history[0].Path, history[len(history)-1].Path)
+	}
+	if history[0].Severity != SeverityError {
+		t.Fatalf("expected error severity, got %v", history[0].Severity)
+	}
+}
+
+func TestPollWatcherEvents(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "app.log")
+	removed := filepath.Join(dir, "old.log")
+	for _, path := range []string{existing, removed} {
+		if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	w := newPollWatcher(time.Hour)
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	if err := os.WriteFile(existing, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("remove file: %v", err)
+	}
+	created := filepath.Join(dir, "new.log")
+	if err := os.WriteFile(created, []byte("x\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	w.poll()
+
+	got := make(map[string]fsnotify.Op)
+	for len(w.events) > 0 {
+		event := <-w.events
+		got[event.Name] |= event.Op
+	}
+	if got[existing] != fsnotify.Write || got[removed] != fsnotify.Remove || got[created] != fsnotify.Create {
+		t.Fatalf("unexpected events: %v", got)
+	}
+}
+
+func TestAddWatchPollMode(t *testing.T) {
+	root := t.TempDir()
+	tailer, err := New(Config{Root: root, Watch: WatchPoll})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	if tailer.watcher != nil {
+		t.Fatalf("expected no fsnotify watcher in poll mode")
+	}
+	if err := tailer.addWatch(root); err != nil {
+		t.Fatalf("addWatch: %v", err)
+	}
+	defer tailer.poller.Close()
+	if tailer.watchedDir[root] != watcher(tailer.poller) {
+		t.Fatalf("expected root to be polled")
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	root := t.TempDir()
+	releases := t.TempDir()
+	for _, dir := range []string{"v1", "v2"} {
+		if err := os.MkdirAll(filepath.Join(releases, dir), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(releases, dir, "app.log"), []byte(dir+"\n"), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.log"), []byte("a\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	for link, target := range map[string]string{
+		"b.log":   "a.log",
+		"current": filepath.Join(releases, "v1"),
+		"loop":    ".",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatalf("symlink: %v", err)
+		}
+	}
+
+	tailer, err := New(Config{Root: root, N: 10, Recursive: true, RecursiveSet: true, FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer tailer.watcher.Close()
+	tailer.lines = make(chan Line, 16)
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+
+	var got []string
+	for _, file := range tailer.Files() {
+		got = append(got, file.Path)
+	}
+	want := []string{"a.log", filepath.Join("current", "app.log")}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	var loops int
+	for _, e := range tailer.ErrorHistory() {
+		if errors.Is(e, errSymlinkLoop) {
+			loops++
+		}
+	}
+	if loops != 1 {
+		t.Fatalf("expected one loop report, got %d", loops)
+	}
+
+	link := filepath.Join(root, "current")
+	if err := os.Remove(link); err != nil {
+		t.Fatalf("remove link: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(releases, "v2"), link); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	defer tailer.getState(filepath.Join(root, "a.log")).close()
+	defer tailer.getState(filepath.Join(link, "app.log")).close()
+
+	close(tailer.lines)
+	got = nil
+	for line := range tailer.lines {
+		got = append(got, line.Path+":"+line.Text)
+	}
+	current := filepath.Join("current", "app.log")
+	want = []string{"a.log:a", current + ":v1", current + ":v2"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestTimestampParser(t *testing.T) {
+	now := time.Date(2026, time.January, 2, 10, 0, 0, 0, time.Local)
+	parser := timestampParser{now: func() time.Time { return now }}
+	cases := []struct {
+		text string
+		want time.Time
+	}{
+		{"2026-01-02T03:04:05.5Z level=info", time.Date(2026, 1, 2, 3, 4, 5, 5e8, time.UTC)},
+		{"2026-01-02 03:04:05,250 INFO start", time.Date(2026, 1, 2, 3, 4, 5, 25e7, time.Local)},
+		{"[2026-01-02T03:04:05+02:00] boot", time.Date(2026, 1, 2, 1, 4, 5, 0, time.UTC)},
+		{"Dec 31 23:59:58 host cron[1]: run", time.Date(2025, 12, 31, 23, 59, 58, 0, time.Local)},
+		{"Jan  2 09:00:00 host sshd[2]: accept", time.Date(2026, 1, 2, 9, 0, 0, 0, time.Local)},
+		{`127.0.0.1 - - [02/Jan/2026:03:04:05 +0000] "GET / HTTP/1.1" 200 5`, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2026/01/02 03:04:05 [error] 12#0: upstream timed out", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)},
+		{"[Fri Jan 02 03:04:05.123456 2026] [core:error] denied", time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.Local)},
+		{"1767323045.25 tick", time.Unix(1767323045, 25e7)},
+		{"1767323045250 tick", time.UnixMilli(1767323045250)},
+	}
+	for _, tc := range cases {
+		got, ok := parser.parse(tc.text)
+		if !ok || !got.Equal(tc.want) {
+			t.Errorf("parse(%q) = %v, %v; want %v", tc.text, got, ok, tc.want)
+		}
+	}
+	if _, ok := parser.parse("    at com.example.Main(Main.java:10)"); ok {
+		t.Errorf("expected no timestamp in continuation line")
+	}
+
+	custom := timestampParser{layout: "02.01.2006 15:04:05.000"}
+	got, ok := custom.parse("02.01.2026 03:04:05.120 worker started")
+	if !ok || !got.Equal(time.Date(2026, 1, 2, 3, 4, 5, 12e7, time.Local)) {
+		t.Errorf("custom layout parse = %v, %v", got, ok)
+	}
+}
+
+func TestOrderByTimeMergesBacklog(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"api.log": "2026-01-02T03:00:01Z api one\n2026-01-02T03:00:04Z api two\n",
+		"db.log":  "2026-01-02T03:00:02Z db one\n    continued\n2026-01-02T03:00:03Z db two\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	tailer, err := New(Config{Root: root, N: 10, Order: OrderTime})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	defer tailer.watcher.Close()
+	tailer.lines = make(chan Line, 16)
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	for name := range files {
+		defer tailer.getState(filepath.Join(root, name)).close()
+	}
+	if len(tailer.lines) != 0 {
+		t.Fatalf("expected backlog to be held until flushed")
+	}
+	tailer.flushReorder(true)
+
+	close(tailer.lines)
+	var got []string
+	for line := range tailer.lines {
+		got = append(got, line.Text)
+	}
+	want := []string{
+		"2026-01-02T03:00:01Z api one",
+		"2026-01-02T03:00:02Z db one",
+		"    continued",
+		"2026-01-02T03:00:03Z db two",
+		"2026-01-02T03:00:04Z api two",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestReorderWindow(t *testing.T) {
+	tailer := &Tailer{lines: make(chan Line, 8), reorder: &reorderBuffer{window: time.Second}}
+	api, db := &fileState{}, &fileState{}
+	now := time.Now()
+	tailer.deliver(api, Line{Text: "2026-01-02T03:00:05Z waited", Time: now.Add(-2 * time.Second)})
+	tailer.deliver(db, Line{Text: "2026-01-02T03:00:04Z earlier", Time: now})
+	tailer.deliver(db, Line{Text: "2026-01-02T03:00:09Z later", Time: now})
+	tailer.flushReorder(false)
+
+	var got []string
+	for len(tailer.lines) > 0 {
+		got = append(got, (<-tailer.lines).Text)
+	}
+	want := []string{"2026-01-02T03:00:04Z earlier", "2026-01-02T03:00:05Z waited"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if len(tailer.reorder.entries) != 1 {
+		t.Fatalf("expected the newest line to stay buffered")
+	}
+}
+
+func TestMultilineRecords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	content := "INFO start\nERROR boom\n  at a()\n  at b()\nINFO next\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	for _, cfg := range []Config{
+		{MultilineIndent: true},
+		{MultilineStart: `^[A-Z]+ `},
+	} {
+		multi, err := compileMultiline(cfg)
+		if err != nil {
+			t.Fatalf("compileMultiline: %v", err)
+		}
+		tailer := newTestTailer(dir, nil, nil, false)
+		tailer.lines = make(chan Line, 8)
+		tailer.multiline = multi
+		state := &fileState{}
+		tailer.states = map[string]*fileState{path: state}
+		if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+			t.Fatalf("readFromOffset: %v", err)
+		}
+		if len(tailer.lines) != 2 {
+			t.Fatalf("expected the last record to be pending, got %d lines", len(tailer.lines))
+		}
+		tailer.flushRecords(false)
+		if len(tailer.lines) != 2 {
+			t.Fatalf("expected the pending record to wait for the timeout")
+		}
+		state.record.last = time.Now().Add(-time.Second)
+		tailer.flushRecords(false)
+
+		close(tailer.lines)
+		var got []string
+		for line := range tailer.lines {
+			got = append(got, line.Text)
+		}
+		want := []string{"INFO start", "ERROR boom\n  at a()\n  at b()", "INFO next"}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestRotatedSiblingsBacklog(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		mod := time.Now().Add(-age)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	gzipped := func(content string) string {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(content))
+		gz.Close()
+		return buf.String()
+	}
+	write("app.log", "l7\n", 0)
+	write("app.log.1", "l5\nl6\n", time.Hour)
+	write("app.log.2.gz", gzipped("l2\nl3\nl4"), 2*time.Hour)
+	write("app-2026-10-01.log.gz", gzipped("l1\n"), 3*time.Hour)
+	write("app.log.swp", "junk\n", 0)
+	write("other.log.1", "junk\n", 0)
+
+	siblings, err := rotatedSiblings(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatalf("rotatedSiblings: %v", err)
+	}
+	var names []string
+	for _, sibling := range siblings {
+		names = append(names, filepath.Base(sibling))
+	}
+	if want := "app.log.1|app.log.2.gz|app-2026-10-01.log.gz"; strings.Join(names, "|") != want {
+		t.Fatalf("expected %s, got %v", want, names)
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	tailer.cfg.N = 6
+	tailer.cfg.RotatedBacklog = true
+	tailer.lines = make(chan Line, 8)
+	state := &fileState{}
+	defer state.close()
+	if err := tailer.initFile(filepath.Join(dir, "app.log"), state); err != nil {
+		t.Fatalf("initFile: %v", err)
+	}
+	close(tailer.lines)
+	var got []string
+	for line := range tailer.lines {
+		if line.Path != "app.log" {
+			t.Fatalf("expected backlog under the current file's path, got %q", line.Path)
+		}
+		got = append(got, line.Text)
+	}
+	if want := "l2|l3|l4|l5|l6|l7"; strings.Join(got, "|") != want {
+		t.Fatalf("expected %s, got %v", want, got)
+	}
+}
+
+func TestRotatedSiblingsOrder(t *testing.T) {
+	dir := t.TempDir()
+	for name, age := range map[string]time.Duration{
+		"app.log":            0,
+		"app.log.1":          3 * time.Hour,
+		"app.log.2":          time.Hour,
+		"app.log-20261001":   2 * time.Hour,
+		"app.log-20260901":   4 * time.Hour,
+		"app.log.3.gz":       5 * time.Hour,
+		"app.log-20261002.1": 30 * time.Minute,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		mod := time.Now().Add(-age)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	siblings, err := rotatedSiblings(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatalf("rotatedSiblings: %v", err)
+	}
+	var names []string
+	for _, sibling := range siblings {
+		names = append(names, filepath.Base(sibling))
+	}
+	if want := "app.log-20261002.1|app.log.1|app.log-20261001|app.log.2|app.log-20260901|app.log.3.gz"; strings.Join(names, "|") != want {
+		t.Fatalf("expected %s, got %v", want, names)
+	}
+}
+
+func TestRotatedBacklogStopsAtUnsupportedSibling(t *testing.T) {
+	dir := t.TempDir()
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte("l0\nl1\n"))
+	gz.Close()
+	for name, content := range map[string]string{
+		"app.log":       "l3\n",
+		"app.log.1.gz":  gzipped.String(),
+		"app.log.2.zst": "zstd",
+		"app.log.3":     "old1\nold2\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	tailer.cfg.N = 6
+	tailer.cfg.RotatedBacklog = true
+	tailer.errs = make(chan *Error, 4)
+	for range 2 {
+		tailer.lines = make(chan Line, 8)
+		state := &fileState{}
+		if err := tailer.initFile(filepath.Join(dir, "app.log"), state); err != nil {
+			t.Fatalf("initFile: %v", err)
+		}
+		state.close()
+		close(tailer.lines)
+		var got []string
+		for line := range tailer.lines {
+			got = append(got, line.Text)
+		}
+		if want := "l0|l1|l3"; strings.Join(got, "|") != want {
+			t.Fatalf("expected %s, got %v", want, got)
+		}
+	}
+	if len(tailer.errs) != 1 {
+		t.Fatalf("expected the zstd sibling to be reported once, got %d errors", len(tailer.errs))
+	}
+	if err := <-tailer.errs; !errors.Is(err, errZstdUnsupported) {
+		t.Fatalf("expected errZstdUnsupported, got %v", err)
+	}
+}
+
+func TestEncodingDetectionAndDecoding(t *testing.T) {
+	dir := t.TempDir()
+	utf16File := filepath.Join(dir, "win.log")
+	var utf16Data []byte
+	utf16Data = append(utf16Data, 0xff, 0xfe)
+	for _, r := range "héllo\r\nwörld\r\npart" {
+		utf16Data = append(utf16Data, byte(r), byte(r>>8))
+	}
+	gbkFile := filepath.Join(dir, "cn.log")
+	gbkData := []byte{0xc4, 0xe3, 0xba, 0xc3, ' ', 0xca, 0xc0, 0xbd, 0xe7, '\n'} // "你好 世界"
+	latinFile := filepath.Join(dir, "legacy.txt")
+	latinData := []byte("caf\xe9\n")
+	for path, data := range map[string][]byte{utf16File: utf16Data, gbkFile: gbkData, latinFile: latinData} {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	if !isTextData(utf16Data) {
+		t.Fatalf("expected UTF-16 with BOM to be detected as text")
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	rules, err := parseEncodingRules([]string{"*.txt=latin1"}, false)
+	if err != nil {
+		t.Fatalf("parseEncodingRules: %v", err)
+	}
+	tailer.encodings = rules
+	if got := tailer.encodingFor(utf16File).String(); got != "utf-16le" {
+		t.Fatalf("expected utf-16le, got %s", got)
+	}
+	if got := tailer.encodingFor(gbkFile).String(); got != "gb18030" {
+		t.Fatalf("expected gb18030, got %s", got)
+	}
+	if got := tailer.encodingFor(latinFile).String(); got != "windows-1252" {
+		t.Fatalf("expected the override to apply, got %s", got)
+	}
+
+	lines, partial, offsets, err := readLastLines(utf16File, 5, utf16LE)
+	if err != nil {
+		t.Fatalf("readLastLines: %v", err)
+	}
+	if strings.Join(lines, "|") != "héllo|wörld" || utf16LE.decode(partial) != "part" {
+		t.Fatalf("unexpected lines %q partial %q", lines, partial)
+	}
+	if len(offsets) != 3 || offsets[1] != 16 || offsets[2] != 30 {
+		t.Fatalf("unexpected offsets: %v", offsets)
+	}
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(utf16Data)
+	gz.Close()
+	if err := os.WriteFile(utf16File+".1.gz", gzipped.Bytes(), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	lines, offsets, err = lastLinesOf(utf16File+".1.gz", 5, utf16LE)
+	if err != nil {
+		t.Fatalf("lastLinesOf: %v", err)
+	}
+	if strings.Join(lines, "|") != "héllo|wörld|part" || len(offsets) != 3 || offsets[2] != 30 {
+		t.Fatalf("unexpected compressed lines %q offsets %v", lines, offsets)
+	}
+
+	tailer.lines = make(chan Line, 8)
+	for _, path := range []string{utf16File, gbkFile, latinFile} {
+		state := &fileState{enc: tailer.encodingFor(path)}
+		if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+			t.Fatalf("readFromOffset: %v", err)
+		}
+		state.close()
+	}
+	close(tailer.lines)
+	var got []string
+	for line := range tailer.lines {
+		got = append(got, line.Text)
+	}
+	want := []string{"héllo", "wörld", "part", "你好 世界", "café"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	encode := func(enc encoding.Encoding, text string) []byte {
+		data, err := enc.NewEncoder().Bytes([]byte(text))
+		if err != nil {
+			t.Fatalf("encode %q: %v", text, err)
+		}
+		return data
+	}
+	cases := []struct {
+		sample []byte
+		want   string
+	}{
+		{encode(charmap.ISO8859_1, "Müller Straße 5 café\n"), "windows-1252"},
+		{encode(charmap.ISO8859_1, "Señor año niño\n"), "windows-1252"},
+		{encode(charmap.ISO8859_1, "naïve résumé façade\n"), "windows-1252"},
+		{encode(simplifiedchinese.GB18030, "服务器启动完成，监听端口 8080\n"), "gb18030"},
+		{[]byte("plain ascii\n"), "utf-8"},
+	}
+	for _, c := range cases {
+		if got := detectEncoding(c.sample).String(); got != c.want {
+			t.Errorf("%q: expected %s, got %s", c.sample, c.want, got)
+		}
+	}
+	latin := encode(charmap.ISO8859_1, "Müller Straße 5 café")
+	if got := detectEncoding(latin).decode(latin); got != "Müller Straße 5 café" {
+		t.Errorf("expected Latin-1 text to round-trip, got %q", got)
+	}
+}
+
+func TestUTF16SplitCodeUnit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "win.log")
+	utf16 := func(s string) []byte {
+		var data []byte
+		for _, r := range s {
+			data = append(data, byte(r), byte(r>>8))
+		}
+		return data
+	}
+	data := append([]byte{0xff, 0xfe}, utf16("one\ntwo\n")...)
+	if err := os.WriteFile(path, data[:len(data)-1], 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	tailer.lines = make(chan Line, 8)
+	state := &fileState{enc: utf16LE}
+	defer state.close()
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, utf16("three\n")...), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := tailer.readNew(path, state); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	close(tailer.lines)
+	var got []string
+	for line := range tailer.lines {
+		if !line.Partial {
+			got = append(got, line.Text)
+		}
+	}
+	if want := "one|two|three"; strings.Join(got, "|") != want {
+		t.Fatalf("expected %s, got %q", want, got)
+	}
+}
+
+func TestDrainDecodesUTF16Partial(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "win.log")
+	var data []byte
+	for _, r := range "one\ntwo" {
+		data = append(data, byte(r), 0)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	tailer.lines = make(chan Line, 8)
+	state := &fileState{enc: utf16LE}
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	tailer.drain(path, state)
+	close(tailer.lines)
+	var last Line
+	for line := range tailer.lines {
+		last = line
+	}
+	if last.Text != "two" || last.Partial || !last.Update {
+		t.Fatalf("expected the drained partial to be decoded, got %#v", last)
+	}
+}
+
+func TestApplyANSI(t *testing.T) {
+	text := "\x1b[31mERROR\x1b[0m \x1b]0;owned\x07done\x1b[2J\b"
+	cases := map[ANSIMode]string{
+		ANSIKeep:     text,
+		ANSIStrip:    "ERROR done",
+		ANSISanitize: "\x1b[31mERROR\x1b[0m ^[]0;owned^Gdone^[[2J^H",
+	}
+	for mode, want := range cases {
+		if got := applyANSI(mode, text); got != want {
+			t.Errorf("%s: expected %q, got %q", mode, want, got)
+		}
+	}
+	if got := StripANSI("\x1b[1;32mok\x1b[m"); got != "ok" {
+		t.Errorf("StripANSI: got %q", got)
+	}
+}
+
+func TestDetectLevel(t *testing.T) {
+	cases := map[string]Level{
+		"2026-10-16 12:00:00 ERROR connection refused":              LevelError,
+		"2026-10-16 12:00:00 INFO retrying after error":             LevelInfo,
+		"[2026-10-16 12:00:00] [warn] 1234#0: upstream slow":        LevelWarn,
+		`{"time":"2026-10-16T12:00:00Z","level":"debug","msg":"x"}`: LevelDebug,
+		`{"level":50,"msg":"pino"}`:                                 LevelError,
+		"ts=2026-10-16T12:00:00Z level=warning msg=slow":            LevelWarn,
+		"E1016 12:00:00.000000    1234 main.go:10] failed":          LevelError,
+		"W1016 12:00:00.000000    1234 main.go:10] slow":            LevelWarn,
+		"<11>Oct 16 12:00:00 host app: boom":                        LevelError,
+		"<14>Oct 16 12:00:00 host app: hello":                       LevelInfo,
+		"TRACE entering handler":                                    LevelTrace,
+		"just an error in lower case":                               LevelUnknown,
+		"log level: 3":                                              LevelUnknown,
+	}
+	for text, want := range cases {
+		if got := detectLevel(text); got != want {
+			t.Errorf("detectLevel(%q) = %v, want %v", text, got, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Fatalf("expected an error for an unknown level")
+	}
+	if level, err := ParseLevel("WARNING"); err != nil || level != LevelWarn {
+		t.Fatalf("ParseLevel(WARNING) = %v, %v", level, err)
+	}
+}
+
+func TestMinLevelFilter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	content := "starting\nINFO ready\nERROR boom\n  at a()\nDEBUG tick\nWARN slow\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	tailer.cfg.MinLevel = LevelWarn
+	tailer.lines = make(chan Line, 8)
+	state := &fileState{}
+	tailer.states = map[string]*fileState{path: state}
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	close(tailer.lines)
+
+	var got []string
+	for line := range tailer.lines {
+		got = append(got, line.Level.String()+":"+line.Text)
+	}
+	want := []string{":starting", "error:ERROR boom", "error:  at a()", "warn:WARN slow"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields := parseFields(FieldsAuto, `{"ts":"2026-10-16T12:00:00Z","msg":"done","latency_ms":512,"http":{"status":502}}`)
+	var keys []string
+	for _, field := range fields {
+		keys = append(keys, field.Key)
+	}
+	if strings.Join(keys, ",") != "ts,msg,latency_ms,http" {
+		t.Fatalf("expected keys in line order, got %v", keys)
+	}
+	if value, ok := fields.Get("http.status"); !ok || FormatValue(value) != "502" {
+		t.Fatalf("expected nested http.status 502, got %v %v", value, ok)
+	}
+	if value, _ := fields.Get("latency_ms"); FormatValue(value) != "512" {
+		t.Fatalf("expected latency_ms 512, got %v", value)
+	}
+
+	fields = parseFields(FieldsAuto, `ts=2026-10-16T12:00:00Z level=warn msg="upstream \"api\" slow" empty=`)
+	want := Fields{
+		{Key: "ts", Value: "2026-10-16T12:00:00Z"},
+		{Key: "level", Value: "warn"},
+		{Key: "msg", Value: `upstream "api" slow`},
+		{Key: "empty", Value: ""},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("expected %#v, got %#v", want, fields)
+	}
+
+	for _, text := range []string{
+		"plain text with a=b inside",
+		"a=1",
+		`{"unterminated": true`,
+		`a="open quote b=2`,
+	} {
+		if fields := parseFields(FieldsAuto, text); fields != nil {
+			t.Fatalf("expected %q to stay unstructured, got %#v", text, fields)
+		}
+	}
+	if fields := parseFields(FieldsJSON, "a=1 b=2"); fields != nil {
+		t.Fatalf("expected json mode to ignore logfmt, got %#v", fields)
+	}
+
+	expanded := PrettyFields(Fields{{Key: "msg", Value: "x"}, {Key: "http", Value: map[string]any{"status": 502}}})
+	if expanded != "msg: x\nhttp: {\n  \"status\": 502\n}" {
+		t.Fatalf("unexpected pretty output %q", expanded)
+	}
+}
+
+func TestFieldLevel(t *testing.T) {
+	tailer := newTestTailer(t.TempDir(), nil, nil, false)
+	state := &fileState{}
+	line := Line{Text: `{"severity":"ERROR","msg":"the level is not info"}`}
+	tailer.classify(state, &line)
+	if line.Level != LevelError || len(line.Fields) != 2 {
+		t.Fatalf("expected error level from fields, got %v %#v", line.Level, line.Fields)
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	tailer := newTestTailer(t.TempDir(), nil, nil, false)
+	line := Line{Path: "api.log", Text: `{"level":"error","service":"api","latency_ms":734,"took":"1.2s","http":{"status":502},"msg":"upstream failed"}`}
+	tailer.classify(&fileState{}, &line)
+
+	cases := map[string]bool{
+		`level>=warn and service=="api" and latency_ms>500`: true,
+		`level>=fatal`:                        false,
+		`level==error`:                        true,
+		`service="api"`:                       true,
+		`service!=api`:                        false,
+		`latency_ms<=734 and latency_ms>=734`: true,
+		`took>1s and took<2s`:                 true,
+		`took>1500ms`:                         false,
+		`http.status==502`:                    true,
+		`msg=~/^upstream/ and msg!~'ok'`:      true,
+		`user`:                                false,
+		`not user and !missing`:               true,
+		`missing!="x"`:                        true,
+		`missing=="x"`:                        false,
+		`service>"aaa"`:                       true,
+		`latency_ms>"9"`:                      false,
+		`path==api.log && text=~"failed"`:     true,
+		`(service==db or service==api) and not level<error`: true,
+		`service==db or service==web || LEVEL`:              false,
+	}
+	for src, want := range cases {
+		query, err := ParseQuery(src)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", src, err)
+		}
+		if got := query.Match(line); got != want {
+			t.Errorf("%q matched %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	cases := map[string]string{
+		``:               "column 1: empty expression",
+		`level=>warn`:    `column 6: unknown operator "=>" (did you mean ">="?)`,
+		`level>=loud`:    `column 8: unknown level "loud" (want trace, debug, info, warn, error or fatal)`,
+		`a==1 and (b>2`:  `column 14: expected ")" to close "(" at column 10, found end of expression`,
+		`a==1)`:          `column 5: unexpected ")" without matching "("`,
+		`a==1 b==2`:      `column 6: expected "and" or "or" before "b"`,
+		`a>`:             `column 3: expected a value after ">", found end of expression`,
+		`a=="open`:       "column 4: unterminated string",
+		`a=~/[x/`:        "column 4: invalid regex: error parsing regexp: missing closing ]: `[x`",
+		`a==1.2.3`:       `column 4: invalid number or duration "1.2.3" (quote it to compare as a string)`,
+		`a and or b`:     `column 7: expected a field name, found "or"`,
+		`a==/x/`:         `column 4: a /regex/ needs =~ or !~, not "=="`,
+		`a==1 # comment`: `column 6: unexpected character '#'`,
+	}
+	for src, want := range cases {
+		_, err := ParseQuery(src)
+		if err == nil || err.Error() != want {
+			t.Errorf("ParseQuery(%q) error = %v, want %s", src, err, want)
+		}
+	}
+
+	_, err := ParseQuery("a>")
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Context() != "a>\n  ^" {
+		t.Fatalf("unexpected context for %v", err)
+	}
+}
+
+func TestWhereFilter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	content := "level=info msg=ok latency_ms=20\nlevel=warn msg=slow latency_ms=900\nplain text\nlevel=error msg=fail latency_ms=5\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	query, err := ParseQuery("latency_ms>500 or level>=error")
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+
+	tailer := newTestTailer(dir, nil, nil, false)
+	tailer.where = query
+	tailer.lines = make(chan Line, 8)
+	state := &fileState{}
+	tailer.states = map[string]*fileState{path: state}
+	if err := tailer.readFromOffset(path, state, 0, false); err != nil {
+		t.Fatalf("readFromOffset: %v", err)
+	}
+	close(tailer.lines)
+
+	var got []string
+	for line := range tailer.lines {
+		got = append(got, line.Text)
+	}
+	want := []string{"level=warn msg=slow latency_ms=900", "level=error msg=fail latency_ms=5"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestANSIModeAppliesOnlyToLines(t *testing.T) {
+	tailer := &Tailer{cfg: Config{ANSI: ANSISanitize}, lines: make(chan Line, 4)}
+	sub, cancel := tailer.Subscribe(Filter{})
+	defer cancel()
+	tailer.sendLine(Line{Path: "app.log", Text: "50%\r100%\a"})
+	if line := <-tailer.lines; line.Text != "50%^M100%^G" {
+		t.Fatalf("expected Lines() to be sanitized, got %q", line.Text)
+	}
+	if line := <-sub; line.Text != "50%\r100%\a" {
+		t.Fatalf("expected subscribers to get the file's text, got %q", line.Text)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tailer := &Tailer{lines: make(chan Line, 64)}
+	all, cancelAll := tailer.Subscribe(Filter{})
+	defer cancelAll()
+	errorsOnly, cancelErrors := tailer.Subscribe(Filter{Match: func(line Line) bool { return line.Level >= LevelError }})
+	defer cancelErrors()
+	oldest, cancelOldest := tailer.Subscribe(Filter{Buffer: 3})
+	defer cancelOldest()
+	newest, cancelNewest := tailer.Subscribe(Filter{Buffer: 3, Overflow: OverflowDropNewest})
+	defer cancelNewest()
+
+	for _, text := range []string{"a", "b", "c", "d", "e"} {
+		level := LevelInfo
+		if text == "c" {
+			level = LevelError
+		}
+		tailer.sendLine(Line{Path: "app.log", Text: text, Level: level})
+	}
+	if len(tailer.lines) != 5 || len(all) != 5 {
+		t.Fatalf("expected Lines() and every subscriber to get all lines, got %d and %d", len(tailer.lines), len(all))
+	}
+	if line := <-errorsOnly; line.Text != "c" || len(errorsOnly) != 0 {
+		t.Fatalf("expected only the error line, got %#v", line)
+	}
+
+	drain := func(ch <-chan Line) []string {
+		var got []string
+		for len(ch) > 0 {
+			got = append(got, (<-ch).Text)
+		}
+		return got
+	}
+	<-oldest
+	<-oldest
+	tailer.sendLine(Line{Path: "app.log", Text: "f"})
+	want := []string{"e", "[ft: 2 lines dropped for this subscriber]", "f"}
+	if got := drain(oldest); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("drop-oldest: expected %q, got %q", want, got)
+	}
+	want = []string{"a", "b", "c"}
+	if got := drain(newest); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("drop-newest: expected %q, got %q", want, got)
+	}
+
+	// A blocked subscriber stalls the sender only until it is cancelled.
+	blocking, cancelBlocking := tailer.Subscribe(Filter{Buffer: 1, Overflow: OverflowBlock})
+	tailer.sendLine(Line{Text: "fills"})
+	sent := make(chan struct{})
+	go func() {
+		tailer.sendLine(Line{Text: "waits"})
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatalf("expected the send to block on a full blocking subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancelBlocking()
+	<-sent
+	if line := <-blocking; line.Text != "fills" {
+		t.Fatalf("expected the buffered line, got %#v", line)
+	}
+	if _, ok := <-blocking; ok {
+		t.Fatalf("expected the cancelled subscription to be closed")
+	}
+
+	tailer.closeSubscribers()
+	drain(all)
+	if _, ok := <-all; ok {
+		t.Fatalf("expected subscriptions to close when the tailer stops")
+	}
+	late, cancelLate := tailer.Subscribe(Filter{})
+	defer cancelLate()
+	if _, ok := <-late; ok {
+		t.Fatalf("expected a subscription after stop to be closed")
+	}
+}
+
+func TestSetFiltersDoesNotBlockOnBlockedLoop(t *testing.T) {
+	stop := make(chan struct{})
+	tailer := &Tailer{
+		cfg:      Config{Overflow: OverflowBlock},
+		lines:    make(chan Line, 1),
+		filterCh: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stop:     stop,
+	}
+	tailer.sendLine(Line{Text: "fills"})
+	blocked := make(chan struct{})
+	go func() {
+		tailer.sendLine(Line{Text: "waits"})
+		close(blocked)
+	}()
+
+	returned := make(chan struct{})
+	go func() {
+		tailer.SetFilters([]string{"*.log"}, nil, false)
+		tailer.SetFilters([]string{"*.txt"}, nil, false)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("SetFilters blocked while the loop was stuck in sendLine")
+	}
+	select {
+	case <-blocked:
+		t.Fatalf("expected sendLine to still be blocked")
+	default:
+	}
+	if tailer.filters == nil || strings.Join(tailer.filters.include, ",") != "*.txt" || len(tailer.filterCh) != 1 {
+		t.Fatalf("expected the latest update to be pending, got %#v", tailer.filters)
+	}
+	close(stop)
+	<-blocked
+}
+
+func TestStartReportsMissingSourceInEveryMode(t *testing.T) {
+	for _, overflow := range []OverflowPolicy{OverflowDropOldest, OverflowBlock} {
+		dir := t.TempDir()
+		tailer, err := New(Config{Root: dir, Overflow: overflow, Watch: WatchPoll})
+		if err != nil {
+			t.Fatalf("new tailer: %v", err)
+		}
+		if err := os.Remove(dir); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+		if err := tailer.Start(make(chan struct{})); err == nil {
+			t.Fatalf("%s: expected Start to fail on a removed source", overflow)
+		}
+		tailer.Discard()
+	}
+}
+
+func TestDiscardClosesFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte("one\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	tailer, err := New(Config{Root: dir, Watch: WatchPoll})
+	if err != nil {
+		t.Fatalf("new tailer: %v", err)
+	}
+	if err := tailer.scanAndRegister(); err != nil {
+		t.Fatalf("scanAndRegister: %v", err)
+	}
+	states := make([]*fileState, 0, len(tailer.states))
+	for _, state := range tailer.states {
+		states = append(states, state)
+	}
+	if len(states) != 1 || states[0].file == nil {
+		t.Fatalf("expected one open file, got %d states", len(states))
+	}
+	tailer.Discard()
+	if states[0].file != nil {
+		t.Fatalf("expected Discard to close the file")
+	}
+}
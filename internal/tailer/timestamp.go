@@ -0,0 +1,136 @@
+package tailer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	isoStampRe    = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}:\d{2}(?:[.,]\d+)?)(Z|[+-]\d{2}:?\d{2})?`)
+	syslogStampRe = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})`)
+	accessStampRe = regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`)
+	nginxStampRe  = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2})`)
+	apacheStampRe = regexp.MustCompile(`^\[([A-Z][a-z]{2} [A-Z][a-z]{2} \d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4})\]`)
+	epochStampRe  = regexp.MustCompile(`^(\d{10}|\d{13})(?:\.(\d{1,9}))?\b`)
+)
+
+// accessStampSearch bounds how far into a line the access log timestamp may
+// appear (it follows the client address and user fields).
+const accessStampSearch = 160
+
+// timestampParser extracts the timestamp a log line starts with. Formats
+// without a zone are read as local time; syslog stamps without a year get
+// the current one.
+type timestampParser struct {
+	layout string
+	now    func() time.Time
+}
+
+func (p timestampParser) parse(text string) (time.Time, bool) {
+	if p.layout != "" {
+		if ts, ok := parseLayoutPrefix(p.layout, text); ok {
+			return ts, true
+		}
+	}
+	if m := isoStampRe.FindStringSubmatch(text); m != nil {
+		value := m[1] + "T" + strings.Replace(m[2], ",", ".", 1)
+		layout := "2006-01-02T15:04:05"
+		loc := time.Local
+		switch {
+		case m[3] == "Z":
+			loc = time.UTC
+		case m[3] != "":
+			value += strings.Replace(m[3], ":", "", 1)
+			layout += "-0700"
+		}
+		if ts, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return ts, true
+		}
+	}
+	if m := apacheStampRe.FindStringSubmatch(text); m != nil {
+		if ts, err := time.ParseInLocation("Mon Jan 02 15:04:05 2006", m[1], time.Local); err == nil {
+			return ts, true
+		}
+	}
+	if m := nginxStampRe.FindStringSubmatch(text); m != nil {
+		if ts, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local); err == nil {
+			return ts, true
+		}
+	}
+	if m := syslogStampRe.FindStringSubmatch(text); m != nil {
+		if ts, err := time.ParseInLocation("Jan _2 15:04:05", m[1], time.Local); err == nil {
+			return p.withYear(ts), true
+		}
+	}
+	if m := epochStampRe.FindStringSubmatch(text); m != nil {
+		if ts, ok := parseEpoch(m[1], m[2]); ok {
+			return ts, true
+		}
+	}
+	head := text
+	if len(head) > accessStampSearch {
+		head = head[:accessStampSearch]
+	}
+	if m := accessStampRe.FindStringSubmatch(head); m != nil {
+		if ts, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1]); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// withYear places a year-less syslog stamp in the current year, or the
+// previous one when that would put it more than a day in the future (lines
+// from late December read in January).
+func (p timestampParser) withYear(ts time.Time) time.Time {
+	now := time.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+	ts = ts.AddDate(now.Year(), 0, 0)
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts
+}
+
+func parseEpoch(whole, frac string) (time.Time, bool) {
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if len(whole) == 13 {
+		return time.UnixMilli(n), true
+	}
+	var nsec int64
+	if frac != "" {
+		frac += strings.Repeat("0", 9-len(frac))
+		nsec, _ = strconv.ParseInt(frac, 10, 64)
+	}
+	return time.Unix(n, nsec), true
+}
+
+// parseLayoutPrefix tries the layout against the start of text, first at the
+// layout's own length and then at the word boundary matching the number of
+// words in the layout, which covers variable-width fields like fractions.
+func parseLayoutPrefix(layout, text string) (time.Time, bool) {
+	text = strings.TrimPrefix(text, "[")
+	candidates := []string{}
+	if len(text) >= len(layout) {
+		candidates = append(candidates, text[:len(layout)])
+	}
+	words := strings.Count(layout, " ") + 1
+	fields := strings.SplitAfterN(text, " ", words+1)
+	if len(fields) >= words {
+		prefix := strings.TrimRight(strings.Join(fields[:words], ""), " ]")
+		candidates = append(candidates, prefix)
+	}
+	for _, candidate := range candidates {
+		if ts, err := time.ParseInLocation(layout, candidate, time.Local); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
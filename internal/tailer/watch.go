@@ -0,0 +1,141 @@
+package tailer
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const defaultPollInterval = time.Second
+
+var errPollFallback = errors.New("polling instead of fsnotify")
+
+type WatchMode int
+
+const (
+	WatchAuto WatchMode = iota
+	WatchFSNotify
+	WatchPoll
+)
+
+func ParseWatchMode(value string) (WatchMode, error) {
+	switch value {
+	case "", "auto":
+		return WatchAuto, nil
+	case "fsnotify":
+		return WatchFSNotify, nil
+	case "poll":
+		return WatchPoll, nil
+	default:
+		return 0, fmt.Errorf("invalid watch mode %q (want auto, fsnotify or poll)", value)
+	}
+}
+
+func (m WatchMode) String() string {
+	switch m {
+	case WatchFSNotify:
+		return "fsnotify"
+	case WatchPoll:
+		return "poll"
+	default:
+		return "auto"
+	}
+}
+
+// watcher reports changes to the entries of watched directories. Both
+// backends speak fsnotify events so the event loop does not care which one a
+// directory ended up on.
+type watcher interface {
+	Add(path string) error
+	Remove(path string) error
+	Close() error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+}
+
+type fsnotifyWatcher struct {
+	*fsnotify.Watcher
+}
+
+func newFSNotifyWatcher() (watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return fsnotifyWatcher{w}, nil
+}
+
+func (w fsnotifyWatcher) Events() <-chan fsnotify.Event {
+	return w.Watcher.Events
+}
+
+func (w fsnotifyWatcher) Errors() <-chan error {
+	return w.Watcher.Errors
+}
+
+func watchEvents(w watcher) <-chan fsnotify.Event {
+	if w == nil {
+		return nil
+	}
+	return w.Events()
+}
+
+func watchErrors(w watcher) <-chan error {
+	if w == nil {
+		return nil
+	}
+	return w.Errors()
+}
+
+func (t *Tailer) pollEvents() <-chan fsnotify.Event {
+	if t.poller == nil {
+		return nil
+	}
+	return t.poller.events
+}
+
+func (t *Tailer) pollErrors() <-chan error {
+	if t.poller == nil {
+		return nil
+	}
+	return t.poller.errors
+}
+
+// addWatch puts a directory on fsnotify, or on the poller when polling is
+// forced, fsnotify is unavailable, the directory lives on a network
+// filesystem, or the kernel has run out of watches.
+func (t *Tailer) addWatch(path string) error {
+	if _, ok := t.watchedDir[path]; ok {
+		return nil
+	}
+	if t.watcher != nil && (t.cfg.Watch != WatchAuto || !isNetworkFS(path)) {
+		err := t.watcher.Add(path)
+		if err == nil {
+			t.watchedDir[path] = t.watcher
+			return nil
+		}
+		if t.cfg.Watch != WatchAuto || !errors.Is(err, syscall.ENOSPC) {
+			return err
+		}
+		t.sendErr(OpWatch, path, fmt.Errorf("%w: %w", errPollFallback, err))
+	}
+	if t.poller == nil {
+		t.poller = newPollWatcher(t.cfg.PollInterval)
+	}
+	if err := t.poller.Add(path); err != nil {
+		return err
+	}
+	t.watchedDir[path] = t.poller
+	return nil
+}
+
+func (t *Tailer) removeWatch(path string) {
+	if w, ok := t.watchedDir[path]; ok {
+		_ = w.Remove(path)
+		delete(t.watchedDir, path)
+	}
+	t.forgetDir(path)
+}
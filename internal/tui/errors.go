@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"folder-tail/internal/tailer"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const errorPanelMaxHeight = 12
+
+var (
+	errorPanelStyle = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderTop(true)
+	errorStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	warningStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+)
+
+type errorPanel struct {
+	open    bool
+	entries []*tailer.Error
+}
+
+type pathErrorCount struct {
+	path  string
+	count int
+}
+
+func (m *Model) refreshErrors() {
+	if m.source == nil {
+		return
+	}
+	m.errors.entries = m.source.ErrorHistory()
+}
+
+// errorPanelHeight includes the top border.
+func (m *Model) errorPanelHeight() int {
+	if !m.errors.open {
+		return 0
+	}
+	return min(errorPanelMaxHeight, max(m.height/3, 3))
+}
+
+func errorCounts(entries []*tailer.Error) []pathErrorCount {
+	index := make(map[string]int)
+	var counts []pathErrorCount
+	for _, err := range entries {
+		path := err.Path
+		if path == "" {
+			path = "(watcher)"
+		}
+		i, ok := index[path]
+		if !ok {
+			i = len(counts)
+			index[path] = i
+			counts = append(counts, pathErrorCount{path: path})
+		}
+		counts[i].count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].count > counts[j].count
+	})
+	return counts
+}
+
+// errorPanelView lists per-path counts first, then the most recent errors,
+// newest first, until the panel is full.
+func (m *Model) errorPanelView() string {
+	height := m.errorPanelHeight() - 1
+	entries := m.errors.entries
+	counts := errorCounts(entries)
+	rows := []string{truncate(fmt.Sprintf("errors: %d recent, %d paths (e close)", len(entries), len(counts)), m.width)}
+	countRows := min(len(counts), max((height-1)/2, 1))
+	for _, c := range counts[:countRows] {
+		rows = append(rows, fmt.Sprintf("%5d  %s", c.count, fitName(c.path, m.width-7)))
+	}
+	for i := len(entries) - 1; i >= 0 && len(rows) < height; i-- {
+		err := entries[i]
+		style := warningStyle
+		if err.Severity == tailer.SeverityError {
+			style = errorStyle
+		}
+		rows = append(rows, fmt.Sprintf("%s %s %s", err.Time.Format("15:04:05"), style.Render(fmt.Sprintf("%-7s", err.Severity)), truncate(err.Error(), m.width-17)))
+	}
+	if len(rows) > height {
+		rows = rows[:height]
+	}
+	return errorPanelStyle.Width(m.width).Render(strings.Join(rows, "\n"))
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"folder-tail/internal/tailer"
+)
+
+type fieldView int
+
+const (
+	fieldsCompact fieldView = iota
+	fieldsExpanded
+	fieldsRaw
+)
+
+func (v fieldView) String() string {
+	switch v {
+	case fieldsExpanded:
+		return "expanded"
+	case fieldsRaw:
+		return "raw"
+	default:
+		return "compact"
+	}
+}
+
+// parseColumns splits a projection like "ts|time level msg" into columns,
+// each a list of alternative keys; the first key present in a line is shown.
+func parseColumns(spec string) [][]string {
+	var columns [][]string
+	for _, column := range strings.FieldsFunc(spec, func(r rune) bool { return r == ' ' || r == ',' }) {
+		columns = append(columns, strings.Split(column, "|"))
+	}
+	return columns
+}
+
+// render turns a buffered line into what the viewport shows: structured
+// lines as the column projection or pretty-printed, colored by level.
+func (m *Model) render(line displayLine) string {
+	view := m.fieldView
+	if line.Expanded {
+		view = fieldsExpanded
+	}
+	if len(line.Fields) == 0 || view == fieldsRaw {
+		return colorLevel(line.Text, line.Level)
+	}
+	if view == fieldsExpanded {
+		return colorLevel(tailer.PrettyFields(line.Fields), line.Level)
+	}
+	var values []string
+	for _, column := range m.columns {
+		for _, key := range column {
+			if value, ok := line.Fields.Get(key); ok {
+				values = append(values, tailer.FormatValue(value))
+				break
+			}
+		}
+	}
+	if len(values) == 0 {
+		return colorLevel(line.Text, line.Level)
+	}
+	text := colorLevel(strings.Join(values, " "), line.Level)
+	if hidden := len(line.Fields) - len(values); hidden > 0 {
+		text += foldStyle.Render(fmt.Sprintf(" [+%d fields]", hidden))
+	}
+	return text
+}
+
+func (m *Model) cycleFieldView() {
+	m.fieldView = (m.fieldView + 1) % 3
+	for i := range m.lines {
+		m.lines[i].Expanded = false
+	}
+	m.refreshViewport()
+	if m.follow && !m.paused {
+		m.viewport.GotoBottom()
+	}
+}
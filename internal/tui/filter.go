@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+)
+
+type filterTerm struct {
+	path   bool
+	negate bool
+	re     *regexp.Regexp
+}
+
+type viewFilter struct {
+	raw   string
+	terms []filterTerm
+}
+
+// parseFilter splits the filter into whitespace-separated terms that must all
+// match. A "path:" prefix matches the file path instead of the line text, and a
+// leading "!" negates the term.
+func parseFilter(raw string) (viewFilter, error) {
+	filter := viewFilter{raw: strings.TrimSpace(raw)}
+	for _, field := range strings.Fields(filter.raw) {
+		term := filterTerm{}
+		if strings.HasPrefix(field, "!") {
+			term.negate = true
+			field = field[1:]
+		}
+		if strings.HasPrefix(field, "path:") {
+			term.path = true
+			field = strings.TrimPrefix(field, "path:")
+		}
+		if field == "" {
+			continue
+		}
+		re, err := compileSearch(field)
+		if err != nil {
+			return viewFilter{}, err
+		}
+		term.re = re
+		filter.terms = append(filter.terms, term)
+	}
+	return filter, nil
+}
+
+func (f viewFilter) active() bool {
+	return len(f.terms) > 0
+}
+
+func (f viewFilter) match(line displayLine) bool {
+	for _, term := range f.terms {
+		value := line.Plain
+		if term.path {
+			value = line.Path
+		}
+		if term.re.MatchString(value) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// setFilter applies the filter as it is typed. An invalid term keeps the
+// last valid filter and is reported in the prompt.
+func (m *Model) setFilter(raw string) bool {
+	filter, err := parseFilter(raw)
+	if err != nil {
+		m.promptErr = err.Error()
+		return false
+	}
+	m.promptErr = ""
+	m.filter = filter
+	m.refreshViewport()
+	if m.follow && !m.paused {
+		m.viewport.GotoBottom()
+	}
+	return true
+}
+
+func (m *Model) visible(line displayLine) bool {
+	return m.fileVisible(line.Path) && m.levelVisible(line) && m.whereMatch(line) && m.filter.match(line)
+}
+
+func (m *Model) setWatchFilters(include, exclude []string) {
+	if m.source == nil {
+		return
+	}
+	if err := m.source.SetFilters(include, exclude, m.forceRegex); err != nil {
+		m.lastErr = err.Error()
+		return
+	}
+	m.include = include
+	m.exclude = exclude
+}
+
+func splitPatterns(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
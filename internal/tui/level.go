@@ -0,0 +1,56 @@
+package tui
+
+import (
+	"strings"
+
+	"folder-tail/internal/tailer"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var levelStyles = map[tailer.Level]lipgloss.Style{
+	tailer.LevelTrace: lipgloss.NewStyle().Faint(true),
+	tailer.LevelDebug: lipgloss.NewStyle().Faint(true),
+	tailer.LevelWarn:  lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+	tailer.LevelError: lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
+	tailer.LevelFatal: lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true),
+}
+
+// levelCycle is the order the level key steps through; LevelUnknown shows
+// everything.
+var levelCycle = []tailer.Level{tailer.LevelUnknown, tailer.LevelDebug, tailer.LevelInfo, tailer.LevelWarn, tailer.LevelError}
+
+// colorLevel colors a line by its level unless it brings its own colors.
+// Each row of a record is styled separately so folding keeps its color.
+func colorLevel(text string, level tailer.Level) string {
+	style, ok := levelStyles[level]
+	if !ok || strings.Contains(text, "\x1b") {
+		return text
+	}
+	rows := strings.Split(text, "\n")
+	for i, row := range rows {
+		if row != "" {
+			rows[i] = style.Render(row)
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+func (m *Model) levelVisible(line displayLine) bool {
+	return m.minLevel == tailer.LevelUnknown || line.Level == tailer.LevelUnknown || line.Level >= m.minLevel
+}
+
+func (m *Model) cycleLevel() {
+	next := levelCycle[0]
+	for i, level := range levelCycle {
+		if level > m.minLevel {
+			next = levelCycle[i]
+			break
+		}
+	}
+	m.minLevel = next
+	m.refreshViewport()
+	if m.follow && !m.paused {
+		m.viewport.GotoBottom()
+	}
+}
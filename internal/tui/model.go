@@ -7,10 +7,14 @@ import (
 
 	"folder-tail/internal/tailer"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
+var foldStyle = lipgloss.NewStyle().Faint(true)
+
 type Config struct {
 	Root       string
 	Absolute   bool
@@ -18,17 +22,44 @@ type Config struct {
 	Exclude    []string
 	ForceRegex bool
 	MaxLines   int
+	Grep       []string
+	GrepV      []string
+	Prefix     bool
+	MinLevel   tailer.Level
+	Where      *tailer.Query
+	Columns    string
+	Listen     string
+}
+
+type Source interface {
+	FileCount() int
+	Files() []tailer.FileStat
+	SetFilters(include, exclude []string, regex bool) error
+	Stats() tailer.Stats
+	ErrorHistory() []*tailer.Error
 }
 
 type displayLine struct {
-	Path    string
-	Text    string
+	Path string
+	Text string
+	// Plain is Text without escape sequences, for filtering.
+	Plain   string
+	Level   tailer.Level
+	Fields  tailer.Fields
 	Partial bool
+	// FoldFlip inverts the global z fold for this record.
+	FoldFlip bool
+	// Expanded shows this record's fields one per row whatever the view.
+	Expanded bool
+}
+
+func newDisplayLine(line tailer.Line) displayLine {
+	return displayLine{Path: line.Path, Text: line.Text, Plain: tailer.StripANSI(line.Text), Level: line.Level, Fields: line.Fields, Partial: line.Partial}
 }
 
 type linesMsg []tailer.Line
 
-type errMsg error
+type errMsg *tailer.Error
 
 type tickMsg time.Time
 
@@ -37,24 +68,45 @@ type Model struct {
 	lines        []displayLine
 	partialIndex map[string]int
 	linesCh      <-chan tailer.Line
-	errsCh       <-chan error
-	fileCountFn  func() int
+	errsCh       <-chan *tailer.Error
+	source       Source
 	root         string
 	absolute     bool
 	include      []string
 	exclude      []string
 	forceRegex   bool
+	grep         []string
+	grepV        []string
 	maxLines     int
 	paused       bool
 	follow       bool
 	lastErr      string
+	errCount     int
+	errors       errorPanel
 	fileCount    int
+	dropped      int64
 	width        int
 	height       int
 	showPrefixes bool
+	lineRows     []int
+	prompt       promptKind
+	promptPrev   string
+	input        textinput.Model
+	search       searchState
+	filter       viewFilter
+	shown        int
+	sidebar      sidebarState
+	soloActive   bool
+	collapsed    bool
+	minLevel     tailer.Level
+	columns      [][]string
+	fieldView    fieldView
+	where        *tailer.Query
+	promptErr    string
+	listen       string
 }
 
-func New(cfg Config, linesCh <-chan tailer.Line, errsCh <-chan error, fileCountFn func() int) Model {
+func New(cfg Config, linesCh <-chan tailer.Line, errsCh <-chan *tailer.Error, source Source) Model {
 	vp := viewport.New(0, 0)
 	return Model{
 		viewport:     vp,
@@ -62,15 +114,24 @@ func New(cfg Config, linesCh <-chan tailer.Line, errsCh <-chan error, fileCountF
 		partialIndex: make(map[string]int),
 		linesCh:      linesCh,
 		errsCh:       errsCh,
-		fileCountFn:  fileCountFn,
+		source:       source,
 		root:         cfg.Root,
 		absolute:     cfg.Absolute,
 		include:      cfg.Include,
 		exclude:      cfg.Exclude,
 		forceRegex:   cfg.ForceRegex,
+		grep:         cfg.Grep,
+		grepV:        cfg.GrepV,
 		maxLines:     cfg.MaxLines,
 		follow:       true,
-		showPrefixes: false,
+		showPrefixes: cfg.Prefix,
+		input:        newPrompt(),
+		search:       searchState{current: -1},
+		sidebar:      sidebarState{modes: make(map[string]fileMode)},
+		minLevel:     cfg.MinLevel,
+		where:        cfg.Where,
+		columns:      parseColumns(cfg.Columns),
+		listen:       cfg.Listen,
 	}
 }
 
@@ -98,18 +159,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, m.listenLines()
 	case errMsg:
-		err := error(msg)
-		if err != nil {
-			m.lastErr = err.Error()
+		if msg != nil {
+			m.lastErr = (*tailer.Error)(msg).Error()
+			m.errCount++
+			if m.errors.open {
+				m.refreshErrors()
+			}
 		}
 		return m, m.listenErrs()
 	case tickMsg:
-		if m.fileCountFn != nil {
-			m.fileCount = m.fileCountFn()
+		if m.source != nil {
+			m.fileCount = m.source.FileCount()
+			m.dropped = m.source.Stats().Dropped
+		}
+		if m.sidebar.open {
+			m.refreshFiles()
+		}
+		if m.errors.open {
+			m.refreshErrors()
 		}
 		return m, tickCmd()
 	default:
 		var cmd tea.Cmd
+		if m.prompt != promptNone {
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
 		m.viewport, cmd = m.viewport.Update(msg)
 		return m, cmd
 	}
@@ -118,10 +193,38 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) View() string {
 	header := m.headerLines()
 	content := m.viewport.View()
+	if m.sidebar.open {
+		content = lipgloss.JoinHorizontal(lipgloss.Top, m.sidebarView(m.viewport.Height), content)
+	}
+	if m.errors.open {
+		content = lipgloss.JoinVertical(lipgloss.Left, content, m.errorPanelView())
+	}
 	return strings.Join(append(header, content), "\n")
 }
 
 func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.prompt != promptNone {
+		return m.handlePromptKey(msg)
+	}
+	if m.sidebar.open {
+		switch msg.String() {
+		case "up", "k":
+			m.moveSelection(-1)
+			return m, nil
+		case "down", "j":
+			m.moveSelection(1)
+			return m, nil
+		case "m":
+			m.toggleMode(func(mode *fileMode) { mode.muted = !mode.muted })
+			return m, nil
+		case "s":
+			m.toggleMode(func(mode *fileMode) { mode.solo = !mode.solo })
+			return m, nil
+		case "P":
+			m.toggleMode(func(mode *fileMode) { mode.pinned = !mode.pinned })
+			return m, nil
+		}
+	}
 	switch msg.String() {
 	case "q", "ctrl+c":
 		return m, tea.Quit
@@ -140,12 +243,85 @@ func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "c":
 		m.lines = nil
 		m.partialIndex = make(map[string]int)
+		m.search.matches = nil
+		m.search.current = -1
 		m.viewport.SetContent("")
 		return m, nil
+	case "/":
+		return m, m.startPrompt(promptSearch, "/", "")
+	case "?":
+		return m, m.startPrompt(promptSearch, "?", "")
+	case "&":
+		return m, m.startPrompt(promptFilter, "&", m.filter.raw)
+	case "I":
+		return m, m.startPrompt(promptInclude, "include: ", strings.Join(m.include, ","))
+	case "X":
+		return m, m.startPrompt(promptExclude, "exclude: ", strings.Join(m.exclude, ","))
+	case "w":
+		src := ""
+		if m.where != nil {
+			src = m.where.String()
+		}
+		return m, m.startPrompt(promptWhere, "where: ", src)
+	case "n":
+		m.jumpMatch(true)
+		return m, nil
+	case "N":
+		m.jumpMatch(false)
+		return m, nil
+	case "esc":
+		if m.search.re != nil {
+			m.clearSearch()
+			m.refreshViewport()
+			return m, nil
+		}
+		m.setFilter("")
+		return m, nil
+	case "z":
+		m.collapsed = !m.collapsed
+		for i := range m.lines {
+			m.lines[i].FoldFlip = false
+		}
+		m.refreshViewport()
+		return m, nil
+	case "Z":
+		if i := m.focusedLine(); i >= 0 {
+			m.lines[i].FoldFlip = !m.lines[i].FoldFlip
+			m.refreshViewport()
+		}
+		return m, nil
+	case "v":
+		m.cycleLevel()
+		return m, nil
+	case "x":
+		if i := m.focusedLine(); i >= 0 {
+			m.lines[i].Expanded = !m.lines[i].Expanded
+			m.refreshViewport()
+		}
+		return m, nil
+	case "F":
+		m.cycleFieldView()
+		return m, nil
 	case "p":
 		m.showPrefixes = !m.showPrefixes
 		m.refreshViewport()
 		return m, nil
+	case "l":
+		m.sidebar.open = !m.sidebar.open
+		if m.sidebar.open {
+			m.refreshFiles()
+		}
+		m.resizeViewport()
+		m.refreshViewport()
+		return m, nil
+	case "e":
+		m.errors.open = !m.errors.open
+		if m.errors.open {
+			m.refreshErrors()
+		}
+		m.resizeViewport()
+		m.refreshViewport()
+		return m, nil
 	case "up", "pgup", "k", "ctrl+u":
 		m.follow = false
 	case "down", "pgdown", "j", "ctrl+d":
@@ -188,35 +364,79 @@ func (m *Model) headerLines() []string {
 	if m.forceRegex {
 		filters += " mode=re"
 	}
+	filters += m.searchStatus()
+	if len(m.grep) > 0 {
+		filters += " grep=" + strings.Join(m.grep, ",")
+	}
+	if len(m.grepV) > 0 {
+		filters += " grep-v=" + strings.Join(m.grepV, ",")
+	}
 	pathMode := "path=group"
 	if m.showPrefixes {
 		pathMode = "path=inline"
 	}
 
-	line1 := fmt.Sprintf("[%s %s] %s root=%s files=%d lines=%d%s", status, follow, pathMode, m.root, m.fileCount, len(m.lines), filters)
+	lineCount := fmt.Sprintf("lines=%d", len(m.lines))
+	if m.filter.active() || len(m.sidebar.modes) > 0 || m.minLevel != tailer.LevelUnknown || m.where != nil {
+		lineCount = fmt.Sprintf("shown=%d/%d", m.shown, len(m.lines))
+	}
+	if m.filter.active() {
+		filters += " filter=" + m.filter.raw
+	}
+	if m.minLevel != tailer.LevelUnknown {
+		filters += " level>=" + m.minLevel.String()
+	}
+	if m.where != nil {
+		filters += " where=" + m.where.String()
+	}
+	if m.fieldView != fieldsCompact {
+		filters += " fields=" + m.fieldView.String()
+	}
+
+	line1 := fmt.Sprintf("[%s %s] %s root=%s files=%d %s%s", status, follow, pathMode, m.root, m.fileCount, lineCount, filters)
+	if m.listen != "" {
+		line1 += " listen=" + m.listen
+	}
+	if m.dropped > 0 {
+		line1 += fmt.Sprintf(" dropped=%d", m.dropped)
+	}
 	if m.lastErr != "" {
-		line1 += " err=" + m.lastErr
+		line1 += fmt.Sprintf(" errors=%d err=%s", m.errCount, m.lastErr)
+	}
+	line2 := "q quit | space pause | f follow | c clear | / ? search | n N next/prev | & filter | w where | esc clear search/filter | z Z fold all/one | v level | x F fields one/all | l files | e errors | I X include/exclude | arrows scroll"
+	if m.sidebar.open {
+		line2 = "files: up/down select | m mute | s solo | P pin | l close | q quit | space pause | f follow | / ? search | & filter"
+	}
+	if m.prompt != promptNone {
+		line2 = m.input.View()
+		if m.promptErr != "" {
+			line2 += "  " + errorStyle.Render(m.promptErr)
+		}
 	}
-	line2 := "q quit | space pause | f follow | c clear | arrows scroll"
 	return []string{line1, line2}
 }
 
 func (m *Model) resizeViewport() {
-	headerHeight := 2
+	headerHeight := 2 + m.errorPanelHeight()
+	width := max(m.width-m.sidebarWidth(), 0)
 	if m.height <= headerHeight {
 		m.viewport.Height = 0
-		m.viewport.Width = m.width
+		m.viewport.Width = width
 		return
 	}
-	m.viewport.Width = m.width
+	m.viewport.Width = width
 	m.viewport.Height = m.height - headerHeight
+	m.clampSidebar()
 }
 
 func (m *Model) applyLine(line tailer.Line) {
 	if line.Update {
 		idx, ok := m.partialIndex[line.Path]
 		if ok && idx >= 0 && idx < len(m.lines) {
-			m.lines[idx] = displayLine{Path: line.Path, Text: line.Text, Partial: line.Partial}
+			next := newDisplayLine(line)
+			next.FoldFlip = m.lines[idx].FoldFlip
+			next.Expanded = m.lines[idx].Expanded
+			m.lines[idx] = next
 			if !line.Partial {
 				delete(m.partialIndex, line.Path)
 			}
@@ -229,7 +449,7 @@ func (m *Model) applyLine(line tailer.Line) {
 }
 
 func (m *Model) appendLine(line tailer.Line) {
-	m.lines = append(m.lines, displayLine{Path: line.Path, Text: line.Text, Partial: line.Partial})
+	m.lines = append(m.lines, newDisplayLine(line))
 	if line.Partial {
 		m.partialIndex[line.Path] = len(m.lines) - 1
 	} else {
@@ -255,51 +475,119 @@ func (m *Model) trimLines() {
 		}
 		m.partialIndex[path] = newIdx
 	}
+	if m.search.current >= 0 {
+		m.search.current -= removeCount
+		if m.search.current < 0 {
+			m.search.current = -1
+		}
+	}
 }
 
 func (m *Model) refreshViewport() {
 	if m.viewport.Height == 0 {
 		return
 	}
+	m.updateMatches()
 	builder := strings.Builder{}
 	first := true
+	row := 0
+	m.shown = 0
+	m.lineRows = m.lineRows[:0]
 	if m.showPrefixes {
-		for _, line := range m.lines {
+		for i, line := range m.lines {
+			if !m.visible(line) {
+				m.lineRows = append(m.lineRows, row)
+				continue
+			}
+			m.shown++
+			line.Text = m.collapse(line, m.highlight(i, m.render(line)))
 			content := formatInlineLine(line)
 			if content == "" {
+				m.lineRows = append(m.lineRows, row)
 				continue
 			}
 			if !first {
 				builder.WriteByte('\n')
+				row++
 			}
 			first = false
+			m.lineRows = append(m.lineRows, row)
 			builder.WriteString(content)
+			if strings.Contains(content, "\x1b") {
+				// Keep a line's colors from bleeding into the next one.
+				builder.WriteString("\x1b[0m")
+			}
+			row += strings.Count(content, "\n")
 		}
 	} else {
 		lastPath := ""
-		for _, line := range m.lines {
+		for i, line := range m.lines {
+			if !m.visible(line) {
+				m.lineRows = append(m.lineRows, row)
+				continue
+			}
+			m.shown++
 			if line.Path != "" && line.Path != lastPath {
 				if !first {
 					builder.WriteByte('\n')
+					row++
 				}
 				first = false
 				builder.WriteString("[" + line.Path + "]")
 				lastPath = line.Path
 			}
+			line.Text = m.collapse(line, m.highlight(i, m.render(line)))
 			content := formatGroupedLine(line)
 			if content == "" {
+				m.lineRows = append(m.lineRows, row)
 				continue
 			}
 			if !first {
 				builder.WriteByte('\n')
+				row++
 			}
 			first = false
+			m.lineRows = append(m.lineRows, row)
 			builder.WriteString(content)
+			if strings.Contains(content, "\x1b") {
+				builder.WriteString("\x1b[0m")
+			}
+			row += strings.Count(content, "\n")
 		}
 	}
 	m.viewport.SetContent(builder.String())
 }
 
+// collapse folds a multiline record to its first line when records are
+// collapsed, or when the record was toggled on its own.
+func (m *Model) collapse(line displayLine, text string) string {
+	if m.collapsed == line.FoldFlip {
+		return text
+	}
+	first, rest, ok := strings.Cut(text, "\n")
+	if !ok {
+		return first
+	}
+	return first + foldStyle.Render(fmt.Sprintf(" [+%d lines]", strings.Count(rest, "\n")+1))
+}
+
+// focusedLine is the record per-record keys act on: the current search
+// match, or else the first record shown at the top of the view.
+func (m *Model) focusedLine() int {
+	if m.search.current >= 0 && m.search.current < len(m.lines) {
+		return m.search.current
+	}
+	if len(m.lineRows) == 0 {
+		return -1
+	}
+	for i := m.lineAtRow(m.viewport.YOffset); i < len(m.lines); i++ {
+		if m.visible(m.lines[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
 func formatInlineLine(line displayLine) string {
 	text := line.Text
 	if line.Partial {
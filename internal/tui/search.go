@@ -0,0 +1,275 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"folder-tail/internal/tailer"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type promptKind int
+
+const (
+	promptNone promptKind = iota
+	promptSearch
+	promptFilter
+	promptInclude
+	promptExclude
+	promptWhere
+)
+
+var (
+	matchStyle        = lipgloss.NewStyle().Reverse(true)
+	currentMatchStyle = lipgloss.NewStyle().Background(lipgloss.Color("11")).Foreground(lipgloss.Color("0"))
+)
+
+type searchState struct {
+	pattern  string
+	re       *regexp.Regexp
+	backward bool
+	matches  []int
+	current  int
+}
+
+func newPrompt() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "/"
+	return input
+}
+
+func (m *Model) startPrompt(kind promptKind, prompt, value string) tea.Cmd {
+	m.prompt = kind
+	m.promptPrev = value
+	m.promptErr = ""
+	m.input.Prompt = prompt
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+	return m.input.Focus()
+}
+
+func (m *Model) handlePromptKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		if m.prompt == promptFilter {
+			m.setFilter(m.promptPrev)
+		}
+		m.prompt = promptNone
+		m.promptErr = ""
+		m.input.Blur()
+		return m, nil
+	case "enter":
+		kind := m.prompt
+		value := m.input.Value()
+		m.prompt = promptNone
+		m.input.Blur()
+		switch kind {
+		case promptSearch:
+			if !m.setSearch(value, m.input.Prompt == "?") {
+				m.prompt = promptSearch
+				return m, m.input.Focus()
+			}
+			m.jumpMatch(true)
+		case promptFilter:
+			if !m.setFilter(value) {
+				m.prompt = promptFilter
+				return m, m.input.Focus()
+			}
+		case promptInclude:
+			m.setWatchFilters(splitPatterns(value), m.exclude)
+		case promptExclude:
+			m.setWatchFilters(m.include, splitPatterns(value))
+		case promptWhere:
+			if !m.setWhere(value) {
+				m.prompt = promptWhere
+				return m, m.input.Focus()
+			}
+		}
+		m.refreshViewport()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	switch m.prompt {
+	case promptSearch:
+		m.checkSearch(m.input.Value())
+	case promptFilter:
+		m.setFilter(m.input.Value())
+	case promptWhere:
+		m.checkWhere(m.input.Value())
+	}
+	return m, cmd
+}
+
+// compileSearch compiles a search or filter pattern. An invalid regular
+// expression is reported rather than matched literally; \Q asks for that.
+func compileSearch(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w (start with \\Q to match literally)", err)
+	}
+	return re, nil
+}
+
+// setSearch starts a search. An invalid pattern leaves the current search in
+// place and is reported in the prompt.
+func (m *Model) setSearch(pattern string, backward bool) bool {
+	m.promptErr = ""
+	if pattern == "" {
+		m.clearSearch()
+		return true
+	}
+	re, err := compileSearch(pattern)
+	if err != nil {
+		m.promptErr = err.Error()
+		return false
+	}
+	m.search = searchState{
+		pattern:  pattern,
+		re:       re,
+		backward: backward,
+		current:  -1,
+	}
+	m.updateMatches()
+	return true
+}
+
+// checkSearch validates the prompt as it is typed without searching.
+func (m *Model) checkSearch(pattern string) {
+	m.promptErr = ""
+	if _, err := compileSearch(pattern); err != nil {
+		m.promptErr = err.Error()
+	}
+}
+
+func (m *Model) clearSearch() {
+	m.search = searchState{current: -1}
+}
+
+func (m *Model) updateMatches() {
+	if m.search.re == nil {
+		return
+	}
+	m.search.matches = m.search.matches[:0]
+	for i, line := range m.lines {
+		if m.visible(line) && m.search.re.MatchString(m.searchText(line)) {
+			m.search.matches = append(m.search.matches, i)
+		}
+	}
+	if m.search.current < 0 {
+		return
+	}
+	idx := sort.SearchInts(m.search.matches, m.search.current)
+	switch {
+	case idx < len(m.search.matches):
+		m.search.current = m.search.matches[idx]
+	case len(m.search.matches) > 0:
+		m.search.current = m.search.matches[len(m.search.matches)-1]
+	default:
+		m.search.current = -1
+	}
+}
+
+// jumpMatch moves to the next match in the search direction, or the opposite
+// direction when forward is false, wrapping around the buffer.
+func (m *Model) jumpMatch(forward bool) {
+	matches := m.search.matches
+	if len(matches) == 0 {
+		return
+	}
+	backward := m.search.backward == forward
+	from := m.search.current
+	if from < 0 {
+		if backward {
+			from = m.lineAtRow(m.viewport.YOffset+m.viewport.Height-1) + 1
+		} else {
+			from = m.lineAtRow(m.viewport.YOffset) - 1
+		}
+	}
+	var idx int
+	if backward {
+		idx = sort.SearchInts(matches, from) - 1
+		if idx < 0 {
+			idx = len(matches) - 1
+		}
+	} else {
+		idx = sort.SearchInts(matches, from+1)
+		if idx >= len(matches) {
+			idx = 0
+		}
+	}
+	next := matches[idx]
+	m.search.current = next
+	m.follow = false
+	m.refreshViewport()
+	if next < len(m.lineRows) {
+		row := m.lineRows[next]
+		m.viewport.SetYOffset(max(row-m.viewport.Height/2, 0))
+	}
+}
+
+func (m *Model) lineAtRow(row int) int {
+	idx := sort.SearchInts(m.lineRows, row)
+	if idx >= len(m.lineRows) {
+		return len(m.lineRows) - 1
+	}
+	return idx
+}
+
+// searchText is what a search matches: the line as rendered, so that every
+// match is one highlight can show.
+func (m *Model) searchText(line displayLine) string {
+	return tailer.StripANSI(m.render(line))
+}
+
+// highlight marks the matches in a rendered line before it is folded.
+func (m *Model) highlight(index int, text string) string {
+	if m.search.re == nil || m.search.pattern == "" {
+		return text
+	}
+	// Lines with a match lose their colors so match offsets line up.
+	plain := tailer.StripANSI(text)
+	locs := m.search.re.FindAllStringIndex(plain, -1)
+	if len(locs) == 0 {
+		return text
+	}
+	text = plain
+	style := matchStyle
+	if index == m.search.current {
+		style = currentMatchStyle
+	}
+	var builder strings.Builder
+	last := 0
+	for _, loc := range locs {
+		if loc[0] == loc[1] {
+			continue
+		}
+		builder.WriteString(text[last:loc[0]])
+		builder.WriteString(style.Render(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+func (m *Model) searchStatus() string {
+	if m.search.re == nil {
+		return ""
+	}
+	dir := "/"
+	if m.search.backward {
+		dir = "?"
+	}
+	pos := 0
+	if m.search.current >= 0 {
+		pos = sort.SearchInts(m.search.matches, m.search.current) + 1
+	}
+	return fmt.Sprintf(" search=%s%s [%d/%d]", dir, m.search.pattern, pos, len(m.search.matches))
+}
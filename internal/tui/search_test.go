@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"folder-tail/internal/tailer"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func newTestModel(t *testing.T, lines ...tailer.Line) *Model {
+	t.Helper()
+	m := New(Config{Columns: "msg"}, nil, nil, nil)
+	m.width, m.height = 80, 12
+	m.resizeViewport()
+	for _, line := range lines {
+		m.appendLine(line)
+	}
+	m.refreshViewport()
+	return &m
+}
+
+// markMatches replaces the match styles, which render nothing without a
+// terminal, with visible brackets.
+func markMatches(t *testing.T) {
+	t.Helper()
+	prevMatch, prevCurrent := matchStyle, currentMatchStyle
+	matchStyle = lipgloss.NewStyle().Transform(func(s string) string { return "[" + s + "]" })
+	currentMatchStyle = lipgloss.NewStyle().Transform(func(s string) string { return "<" + s + ">" })
+	t.Cleanup(func() { matchStyle, currentMatchStyle = prevMatch, prevCurrent })
+}
+
+func typeKeys(m *Model, keys ...string) {
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		m.handleKey(msg)
+	}
+}
+
+func TestSearchReportsInvalidPattern(t *testing.T) {
+	m := newTestModel(t, tailer.Line{Text: "one"}, tailer.Line{Text: "two"})
+	typeKeys(m, "/", "t", "w", "o", "enter")
+	if m.search.pattern != "two" || len(m.search.matches) != 1 {
+		t.Fatalf("expected a search for two, got %#v", m.search)
+	}
+
+	typeKeys(m, "/", "(")
+	if !strings.Contains(m.promptErr, `\Q`) {
+		t.Fatalf("expected the error while typing, got %q", m.promptErr)
+	}
+	typeKeys(m, "enter")
+	if m.prompt != promptSearch || m.promptErr == "" {
+		t.Fatalf("expected the prompt to stay open with the error, got prompt %d, error %q", m.prompt, m.promptErr)
+	}
+	if m.search.pattern != "two" {
+		t.Fatalf("expected the previous search to stay, got %q", m.search.pattern)
+	}
+
+	typeKeys(m, "esc")
+	if m.prompt != promptNone || m.promptErr != "" {
+		t.Fatalf("expected esc to close the prompt and clear the error")
+	}
+}
+
+func TestSearchNavigation(t *testing.T) {
+	m := newTestModel(t,
+		tailer.Line{Text: "a match"},
+		tailer.Line{Text: "b"},
+		tailer.Line{Text: "c match"},
+		tailer.Line{Text: "d match"},
+	)
+	typeKeys(m, "/", "m", "a", "t", "c", "h", "enter")
+	for _, step := range []struct {
+		key  string
+		want int
+	}{
+		{"", 0},
+		{"n", 2},
+		{"n", 3},
+		{"n", 0},
+		{"N", 3},
+		{"N", 2},
+	} {
+		if step.key != "" {
+			typeKeys(m, step.key)
+		}
+		if m.search.current != step.want {
+			t.Fatalf("after %q expected match %d, got %d", step.key, step.want, m.search.current)
+		}
+	}
+	if status := m.searchStatus(); status != " search=/match [2/3]" {
+		t.Fatalf("unexpected status %q", status)
+	}
+
+	typeKeys(m, "esc", "?", "m", "a", "t", "c", "h", "enter")
+	if m.search.current != 3 {
+		t.Fatalf("expected a backward search to start at the last match, got %d", m.search.current)
+	}
+	typeKeys(m, "n")
+	if m.search.current != 2 {
+		t.Fatalf("expected n to go backward, got %d", m.search.current)
+	}
+}
+
+func TestSearchHighlightsWhatItMatches(t *testing.T) {
+	markMatches(t)
+	fields := tailer.Fields{{Key: "msg", Value: "hello"}, {Key: "user", Value: "bob"}}
+	m := newTestModel(t,
+		tailer.Line{Text: `{"msg":"hello","user":"bob"}`, Fields: fields},
+		tailer.Line{Text: "bob was here"},
+	)
+
+	// The compact view hides the user field, so only the plain line matches.
+	typeKeys(m, "/", "b", "o", "b", "enter")
+	if len(m.search.matches) != 1 || m.search.matches[0] != 1 {
+		t.Fatalf("expected only the shown bob to match, got %v", m.search.matches)
+	}
+	if view := m.viewport.View(); !strings.Contains(view, "<bob> was here") || strings.Contains(view, "[bob]") {
+		t.Fatalf("expected the current match highlighted, got %q", view)
+	}
+
+	typeKeys(m, "F")
+	if len(m.search.matches) != 2 {
+		t.Fatalf("expected the expanded view to match both lines, got %v", m.search.matches)
+	}
+	if view := m.viewport.View(); !strings.Contains(view, "user: [bob]") || !strings.Contains(view, "<bob> was here") {
+		t.Fatalf("expected both matches highlighted, got %q", view)
+	}
+}
+
+func TestSearchHighlightsFoldedRecord(t *testing.T) {
+	markMatches(t)
+	m := newTestModel(t, tailer.Line{Text: "ERROR boom\n  at a()"})
+	typeKeys(m, "z", "/", "b", "o", "o", "m", "enter")
+	if view := m.viewport.View(); !strings.Contains(view, "ERROR <boom> [+1 lines]") {
+		t.Fatalf("expected the folded record's match highlighted, got %q", view)
+	}
+}
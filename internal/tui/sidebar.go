@@ -0,0 +1,211 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"folder-tail/internal/tailer"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	sidebarMinWidth = 24
+	sidebarMaxWidth = 48
+)
+
+var (
+	sidebarStyle         = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderRight(true)
+	sidebarSelectedStyle = lipgloss.NewStyle().Reverse(true)
+)
+
+type fileMode struct {
+	muted  bool
+	solo   bool
+	pinned bool
+}
+
+type sidebarState struct {
+	open     bool
+	files    []tailer.FileStat
+	modes    map[string]fileMode
+	selected string
+	offset   int
+}
+
+func (m *Model) refreshFiles() {
+	if m.source == nil {
+		return
+	}
+	files := m.source.Files()
+	sort.SliceStable(files, func(i, j int) bool {
+		pi := m.sidebar.modes[files[i].Path].pinned
+		pj := m.sidebar.modes[files[j].Path].pinned
+		if pi != pj {
+			return pi
+		}
+		return files[i].Path < files[j].Path
+	})
+	m.sidebar.files = files
+	if m.sidebar.selected == "" && len(files) > 0 {
+		m.sidebar.selected = files[0].Path
+	}
+	m.clampSidebar()
+}
+
+func (m *Model) selectedIndex() int {
+	for i, file := range m.sidebar.files {
+		if file.Path == m.sidebar.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Model) moveSelection(delta int) {
+	if len(m.sidebar.files) == 0 {
+		return
+	}
+	idx := m.selectedIndex() + delta
+	idx = min(max(idx, 0), len(m.sidebar.files)-1)
+	m.sidebar.selected = m.sidebar.files[idx].Path
+	m.clampSidebar()
+}
+
+// clampSidebar scrolls the file list so the selection stays in view. It runs
+// from Update whenever the files, the selection or the height change, since
+// View works on a copy of the model.
+func (m *Model) clampSidebar() {
+	height := m.viewport.Height
+	if height <= 0 {
+		return
+	}
+	if selected := m.selectedIndex(); selected >= 0 {
+		if selected < m.sidebar.offset {
+			m.sidebar.offset = selected
+		}
+		if selected >= m.sidebar.offset+height {
+			m.sidebar.offset = selected - height + 1
+		}
+	}
+	m.sidebar.offset = min(m.sidebar.offset, max(len(m.sidebar.files)-height, 0))
+}
+
+func (m *Model) toggleMode(apply func(*fileMode)) {
+	path := m.sidebar.selected
+	if path == "" {
+		return
+	}
+	mode := m.sidebar.modes[path]
+	apply(&mode)
+	if mode == (fileMode{}) {
+		delete(m.sidebar.modes, path)
+	} else {
+		m.sidebar.modes[path] = mode
+	}
+	m.soloActive = m.hasSolo()
+	m.refreshFiles()
+	m.refreshViewport()
+	if m.follow && !m.paused {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *Model) hasSolo() bool {
+	for _, mode := range m.sidebar.modes {
+		if mode.solo {
+			return true
+		}
+	}
+	return false
+}
+
+// fileVisible reports whether lines from path should be shown. Muted files are
+// hidden; when any file is soloed only soloed and pinned files are shown.
+func (m *Model) fileVisible(path string) bool {
+	mode := m.sidebar.modes[path]
+	if mode.muted {
+		return false
+	}
+	if m.soloActive && !mode.solo && !mode.pinned {
+		return false
+	}
+	return true
+}
+
+func (m *Model) sidebarWidth() int {
+	if !m.sidebar.open {
+		return 0
+	}
+	return min(max(m.width/3, sidebarMinWidth), sidebarMaxWidth)
+}
+
+func (m *Model) sidebarView(height int) string {
+	width := m.sidebarWidth() - sidebarStyle.GetHorizontalFrameSize()
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+	selected := m.selectedIndex()
+	now := time.Now()
+	rows := make([]string, 0, height)
+	for i := m.sidebar.offset; i < len(m.sidebar.files) && len(rows) < height; i++ {
+		file := m.sidebar.files[i]
+		mode := m.sidebar.modes[file.Path]
+		flags := []byte("   ")
+		if mode.pinned {
+			flags[0] = 'P'
+		}
+		if mode.solo {
+			flags[1] = 'S'
+		}
+		if mode.muted {
+			flags[2] = 'M'
+		}
+		stats := fmt.Sprintf(" %d %s", file.Lines, formatAge(now, file.LastActivity))
+		nameWidth := width - len(flags) - 1 - len(stats)
+		row := string(flags) + " " + fitName(file.Path, nameWidth) + stats
+		if i == selected {
+			row = sidebarSelectedStyle.Render(row)
+		}
+		rows = append(rows, row)
+	}
+	return sidebarStyle.Width(width).Height(height).MaxHeight(height).Render(strings.Join(rows, "\n"))
+}
+
+// fitName pads or shortens path to width terminal cells, keeping the end of
+// the path (or the start of the base name when even that does not fit).
+func fitName(path string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if pathWidth := ansi.StringWidth(path); pathWidth > width {
+		base := filepath.Base(path)
+		if ansi.StringWidth(base) < width {
+			path = ansi.TruncateLeft(path, pathWidth-width+1, "…")
+		} else {
+			path = ansi.Truncate(base, width, "")
+		}
+	}
+	return path + strings.Repeat(" ", max(width-ansi.StringWidth(path), 0))
+}
+
+func formatAge(now, t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	age := now.Sub(t)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
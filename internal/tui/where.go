@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"folder-tail/internal/tailer"
+)
+
+// setWhere applies a field expression to the view. An invalid expression
+// leaves the current one in place and is reported in the prompt.
+func (m *Model) setWhere(src string) bool {
+	if src == "" {
+		m.where = nil
+		m.promptErr = ""
+		return true
+	}
+	query, err := tailer.ParseQuery(src)
+	if err != nil {
+		m.promptErr = err.Error()
+		return false
+	}
+	m.where = query
+	m.promptErr = ""
+	return true
+}
+
+// checkWhere validates the prompt as it is typed without applying it.
+func (m *Model) checkWhere(src string) {
+	m.promptErr = ""
+	if src == "" {
+		return
+	}
+	if _, err := tailer.ParseQuery(src); err != nil {
+		m.promptErr = err.Error()
+	}
+}
+
+func (m *Model) whereMatch(line displayLine) bool {
+	if m.where == nil {
+		return true
+	}
+	return m.where.Match(tailer.Line{Path: line.Path, Text: line.Text, Level: line.Level, Fields: line.Fields})
+}
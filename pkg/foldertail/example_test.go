@@ -0,0 +1,66 @@
+package foldertail_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"folder-tail/pkg/foldertail"
+)
+
+func Example() {
+	dir, _ := os.MkdirTemp("", "foldertail")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, "app.log"), []byte("starting\nlevel=info msg=ready\nlevel=error msg=\"disk full\"\n"), 0644)
+
+	tail, err := foldertail.New(dir, foldertail.WithLastLines(2), foldertail.WithInclude("*.log"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer tail.Close()
+	if err := tail.Start(context.Background()); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		line := <-tail.Lines()
+		msg, _ := line.Fields.Get("msg")
+		fmt.Printf("%s %s: %s\n", line.Path, line.Level, msg)
+	}
+	// Output:
+	// app.log info: ready
+	// app.log error: disk full
+}
+
+func ExampleTailer_Subscribe() {
+	dir, _ := os.MkdirTemp("", "foldertail")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, "api.log"), []byte("WARN slow request\nINFO ok\nERROR upstream failed\n"), 0644)
+
+	tail, err := foldertail.New(dir)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	alerts, cancel := tail.Subscribe(foldertail.Filter{
+		Match:    func(line foldertail.Line) bool { return line.Level >= foldertail.LevelWarn },
+		Buffer:   100,
+		Overflow: foldertail.OverflowDropNewest,
+	})
+	defer cancel()
+	if err := tail.Start(context.Background()); err != nil {
+		fmt.Println(err)
+		return
+	}
+	tail.Close()
+
+	for line := range alerts {
+		fmt.Println(line.Level, line.Text)
+	}
+	// Output:
+	// warn WARN slow request
+	// error ERROR upstream failed
+}
@@ -0,0 +1,294 @@
+// Package foldertail tails every text file under one or more directories,
+// picking up new files as they appear and following rotation, the engine
+// behind the ft command.
+//
+// A Tailer is created with New, started with Start and stopped by cancelling
+// the context given to Start or by calling Close:
+//
+//	t, err := foldertail.New("/var/log", foldertail.WithInclude("*.log"))
+//	if err != nil {
+//		return err
+//	}
+//	defer t.Close()
+//	if err := t.Start(ctx); err != nil {
+//		return err
+//	}
+//	for line := range t.Lines() {
+//		fmt.Println(line.Path, line.Text)
+//	}
+//
+// Lines() is the primary stream and must be drained: with the default
+// drop-oldest overflow policy a slow reader loses lines, with OverflowBlock
+// it pauses reading. Additional consumers use Subscribe, each with its own
+// buffer and overflow policy. Lines(), Errors() and every subscription are
+// closed once the tailer has stopped, after which Done() is closed.
+package foldertail
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"folder-tail/internal/tailer"
+)
+
+// Line is one line of a tailed file, or a marker written by the tailer.
+//
+//   - Path is the display path: relative to its source (prefixed by the
+//     source's Label, if any), or absolute with WithAbsolutePaths.
+//   - AbsPath is the file's absolute path.
+//   - Text is the decoded line without its newline, truncated to
+//     WithMaxLineBytes.
+//   - Offset is the byte offset of the line in the file, and LineNo its
+//     1-based line number, or 0 when the start of the file was not read.
+//   - Time is when the line was read; Timestamp is the time parsed from the
+//     line with WithTimeOrder.
+//   - Level is the detected log level, inherited from the previous line of
+//     the file when the line has none.
+//   - Fields holds the keys of a JSON or logfmt line, in order.
+//   - Partial marks a final line without a newline yet; the next line of the
+//     file has Update set and replaces it.
+//   - Marker is set on lines written by the tailer: rotation notices, "--"
+//     between grep context groups, and dropped-line counts.
+type Line = tailer.Line
+
+// Field is one key of a structured line. JSON values keep their decoded type
+// (string, json.Number, bool, nil, map[string]any or []any); logfmt values
+// are strings.
+type Field = tailer.Field
+
+// Fields is a line's keys in order. Fields.Get(key) returns a key's value,
+// looking into nested JSON objects for dotted keys ("http.status").
+type Fields = tailer.Fields
+
+// Error is a problem with one file or directory: Path, the operation (Op)
+// that failed, when (Time), its Severity and the underlying Err, which
+// errors.Is and errors.As see through Unwrap.
+type Error = tailer.Error
+
+// Op is the operation an Error comes from.
+type Op = tailer.Op
+
+// Severity is SeverityWarning for routine problems in a live tree (a file
+// vanished or is unreadable, polling instead of fsnotify) and SeverityError
+// for the rest. String returns "warning" or "error".
+type Severity = tailer.Severity
+
+// FileStat describes a tracked file: its display Path, AbsPath, the number
+// of Lines read since start and the time of its LastActivity.
+type FileStat = tailer.FileStat
+
+// Stats reports the lines Queued in Lines() and those Dropped by its
+// overflow policy, in total and per display path (DroppedByPath).
+type Stats = tailer.Stats
+
+// Source is a file or directory to tail (Path) with an optional Label that
+// replaces the path as the display prefix of its lines.
+type Source = tailer.Source
+
+// Filter configures a subscription. Match selects lines, markers included
+// (nil receives every line); it runs on the tailer's goroutine and must not
+// block. Buffer is the queue size (default 1024) and Overflow decides what
+// happens when it is full; OverflowBlock stalls the tailer only while this
+// subscriber is behind.
+type Filter = tailer.Filter
+
+// Level is a detected log level, ordered from LevelTrace to LevelFatal;
+// LevelUnknown is a line without one. String returns its lowercase name.
+type Level = tailer.Level
+
+// OverflowPolicy decides what happens when a consumer's queue is full: drop
+// the oldest queued line, drop the new line, or block reading until there
+// is room. String returns the flag value ("drop-oldest").
+type OverflowPolicy = tailer.OverflowPolicy
+
+// WatchMode picks how changes are detected: fsnotify with polling as a
+// fallback (WatchAuto), fsnotify only, or polling only.
+type WatchMode = tailer.WatchMode
+
+// ANSIMode is how escape sequences in Lines() are handled: ANSISanitize
+// keeps colors and styles and shows other sequences and control characters
+// in caret notation, ANSIKeep passes them through and ANSIStrip removes them.
+type ANSIMode = tailer.ANSIMode
+
+// FieldFormat is which structured formats are parsed into Line.Fields.
+type FieldFormat = tailer.FieldFormat
+
+const (
+	LevelUnknown = tailer.LevelUnknown
+	LevelTrace   = tailer.LevelTrace
+	LevelDebug   = tailer.LevelDebug
+	LevelInfo    = tailer.LevelInfo
+	LevelWarn    = tailer.LevelWarn
+	LevelError   = tailer.LevelError
+	LevelFatal   = tailer.LevelFatal
+)
+
+const (
+	OverflowDropOldest = tailer.OverflowDropOldest
+	OverflowDropNewest = tailer.OverflowDropNewest
+	OverflowBlock      = tailer.OverflowBlock
+)
+
+const (
+	WatchAuto     = tailer.WatchAuto
+	WatchFSNotify = tailer.WatchFSNotify
+	WatchPoll     = tailer.WatchPoll
+)
+
+const (
+	ANSISanitize = tailer.ANSISanitize
+	ANSIKeep     = tailer.ANSIKeep
+	ANSIStrip    = tailer.ANSIStrip
+)
+
+const (
+	FieldsAuto   = tailer.FieldsAuto
+	FieldsJSON   = tailer.FieldsJSON
+	FieldsLogfmt = tailer.FieldsLogfmt
+	FieldsOff    = tailer.FieldsOff
+)
+
+const (
+	SeverityWarning = tailer.SeverityWarning
+	SeverityError   = tailer.SeverityError
+)
+
+const (
+	OpWatch      = tailer.OpWatch
+	OpStat       = tailer.OpStat
+	OpRead       = tailer.OpRead
+	OpWalk       = tailer.OpWalk
+	OpTextDetect = tailer.OpTextDetect
+	OpCheckpoint = tailer.OpCheckpoint
+)
+
+var (
+	// ErrStarted is returned by Start on a tailer that was already started.
+	ErrStarted = errors.New("foldertail: already started")
+	// ErrClosed is returned by Start after Close.
+	ErrClosed = errors.New("foldertail: closed")
+)
+
+// StripANSI removes escape sequences from a line's text.
+func StripANSI(text string) string {
+	return tailer.StripANSI(text)
+}
+
+// Tailer tails the files under its sources. Create one with New.
+type Tailer struct {
+	inner   *tailer.Tailer
+	regex   bool
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	cancel  context.CancelFunc
+}
+
+// New prepares a tailer for path (a directory, tailed recursively, or a
+// single file) and any sources added with WithSources. Nothing is read until
+// Start. Invalid options, such as a bad regex or where expression, are
+// reported here.
+func New(path string, opts ...Option) (*Tailer, error) {
+	o := defaultOptions()
+	o.cfg.Sources = []tailer.Source{{Path: path}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	inner, err := tailer.New(o.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Tailer{inner: inner, regex: o.cfg.ForceRegex}, nil
+}
+
+// Start reads the initial backlog and begins following changes until ctx is
+// cancelled or Close is called. With OverflowBlock the backlog is read in
+// the background so that Start does not wait for Lines() to be drained.
+func (t *Tailer) Start(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	if t.started {
+		return ErrStarted
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if err := t.inner.Start(ctx.Done()); err != nil {
+		cancel()
+		return err
+	}
+	t.started = true
+	t.cancel = cancel
+	return nil
+}
+
+// Close stops the tailer and waits until its channels are closed and
+// checkpoints are saved. It is safe to call more than once, and before
+// Start. Lines() must not be left blocked by an OverflowBlock consumer
+// that has stopped reading, or Close waits for it.
+func (t *Tailer) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		<-t.inner.Done()
+		return nil
+	}
+	t.closed = true
+	started := t.started
+	t.mu.Unlock()
+
+	if !started {
+		return t.inner.Discard()
+	}
+	t.cancel()
+	<-t.inner.Done()
+	return nil
+}
+
+// Lines is the primary stream of lines, which must be drained. It is closed
+// when the tailer stops.
+func (t *Tailer) Lines() <-chan Line {
+	return t.inner.Lines()
+}
+
+// Errors reports problems with individual files and directories; the tailer
+// keeps running. Errors are dropped when the channel is full, but the most
+// recent ones stay available from ErrorHistory.
+func (t *Tailer) Errors() <-chan *Error {
+	return t.inner.Errors()
+}
+
+// Done is closed once the tailer has stopped and all channels are closed.
+func (t *Tailer) Done() <-chan struct{} {
+	return t.inner.Done()
+}
+
+// Subscribe returns an independent stream of the lines passing filter, and a
+// function that ends it. See Filter for buffering and overflow.
+func (t *Tailer) Subscribe(filter Filter) (<-chan Line, func()) {
+	return t.inner.Subscribe(filter)
+}
+
+// Files lists the files currently tracked.
+func (t *Tailer) Files() []FileStat {
+	return t.inner.Files()
+}
+
+// Stats counts lines dropped from Lines().
+func (t *Tailer) Stats() Stats {
+	return t.inner.Stats()
+}
+
+// ErrorHistory returns the most recent errors, oldest first, including those
+// dropped from a full Errors() channel.
+func (t *Tailer) ErrorHistory() []*Error {
+	return t.inner.ErrorHistory()
+}
+
+// SetFilters replaces the include and exclude patterns while running: newly
+// matching files are tailed and files that no longer match are dropped.
+func (t *Tailer) SetFilters(include, exclude []string) error {
+	return t.inner.SetFilters(include, exclude, t.regex)
+}
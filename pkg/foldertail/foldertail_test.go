@@ -0,0 +1,222 @@
+package foldertail_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"folder-tail/pkg/foldertail"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+}
+
+func nextLine(t *testing.T, lines <-chan foldertail.Line) foldertail.Line {
+	t.Helper()
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			t.Fatalf("channel closed")
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a line")
+	}
+	return foldertail.Line{}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	for name, opt := range map[string]foldertail.Option{
+		"grep":      foldertail.WithGrep("("),
+		"where":     foldertail.WithWhere("level>=loud"),
+		"multiline": foldertail.WithMultilineStart("["),
+		"encoding":  foldertail.WithEncoding("klingon"),
+	} {
+		if _, err := foldertail.New(dir, opt); err == nil {
+			t.Errorf("%s: expected New to fail", name)
+		}
+	}
+}
+
+func TestGrepOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.log"), "a\nb\nERROR a.b\nc\nd\ne\nerror axb\nf\n")
+
+	tail, err := foldertail.New(dir,
+		foldertail.WithFromStart(),
+		foldertail.WithScanInterval(0),
+		foldertail.WithGrep("error a.b"),
+		foldertail.WithGrepFixed(),
+		foldertail.WithGrepIgnoreCase(),
+		foldertail.WithGrepContext(1, 2),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer tail.Close()
+	if err := tail.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var got []string
+	for range 4 {
+		got = append(got, nextLine(t, tail.Lines()).Text)
+	}
+	if want := "b|ERROR a.b|c|d"; strings.Join(got, "|") != want {
+		t.Fatalf("expected %s, got %v", want, got)
+	}
+	select {
+	case line := <-tail.Lines():
+		t.Fatalf("unexpected line %#v", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTailFollowsAndStopsWithContext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "one\ntwo\nthree\n")
+	writeFile(t, filepath.Join(dir, "skip.txt"), "ignored\n")
+
+	tail, err := foldertail.New(dir, foldertail.WithLastLines(2), foldertail.WithInclude("*.log"), foldertail.WithScanInterval(0))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := tail.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := tail.Start(ctx); !errors.Is(err, foldertail.ErrStarted) {
+		t.Fatalf("expected ErrStarted, got %v", err)
+	}
+
+	for _, want := range []string{"two", "three"} {
+		if line := nextLine(t, tail.Lines()); line.Text != want || line.Path != "app.log" {
+			t.Fatalf("expected %q from app.log, got %#v", want, line)
+		}
+	}
+	appendFile(t, path, "level=warn msg=four\n")
+	line := nextLine(t, tail.Lines())
+	if line.Text != "level=warn msg=four" || line.Level != foldertail.LevelWarn {
+		t.Fatalf("unexpected line %#v", line)
+	}
+	if msg, ok := line.Fields.Get("msg"); !ok || msg != "four" {
+		t.Fatalf("expected parsed fields, got %#v", line.Fields)
+	}
+	if files := tail.Files(); len(files) != 1 || files[0].Path != "app.log" {
+		t.Fatalf("unexpected files %#v", files)
+	}
+
+	cancel()
+	select {
+	case <-tail.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Done after the context is cancelled")
+	}
+	for range tail.Lines() {
+	}
+	if _, ok := <-tail.Errors(); ok {
+		t.Fatalf("expected Errors to be closed")
+	}
+	if err := tail.Close(); err != nil {
+		t.Fatalf("Close after cancel: %v", err)
+	}
+}
+
+func TestCloseStopsAndIsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.log"), "one\n")
+
+	tail, err := foldertail.New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := tail.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	lines, cancel := tail.Subscribe(foldertail.Filter{})
+	defer cancel()
+	if err := tail.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := tail.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	select {
+	case <-tail.Done():
+	default:
+		t.Fatalf("expected Done to be closed when Close returns")
+	}
+	if _, ok := <-lines; ok {
+		t.Fatalf("expected subscriptions to be closed")
+	}
+	if err := tail.Start(context.Background()); !errors.Is(err, foldertail.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCloseBeforeStart(t *testing.T) {
+	tail, err := foldertail.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := tail.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, ok := <-tail.Lines(); ok {
+		t.Fatalf("expected Lines to be closed")
+	}
+	<-tail.Done()
+}
+
+func TestSubscribersAreIndependent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "")
+
+	tail, err := foldertail.New(dir, foldertail.WithLastLines(0))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer tail.Close()
+	errorsOnly, cancel := tail.Subscribe(foldertail.Filter{
+		Match: func(line foldertail.Line) bool { return line.Level >= foldertail.LevelError },
+	})
+	defer cancel()
+	if err := tail.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	appendFile(t, path, "INFO ok\nERROR boom\n")
+	var got []string
+	for len(got) < 2 {
+		got = append(got, nextLine(t, tail.Lines()).Text)
+	}
+	if strings.Join(got, "|") != "INFO ok|ERROR boom" {
+		t.Fatalf("expected Lines() to see every line, got %q", got)
+	}
+	if line := nextLine(t, errorsOnly); line.Text != "ERROR boom" {
+		t.Fatalf("expected the subscriber to see only errors, got %#v", line)
+	}
+}
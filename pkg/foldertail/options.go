@@ -0,0 +1,206 @@
+package foldertail
+
+import (
+	"time"
+
+	"folder-tail/internal/tailer"
+)
+
+// Option configures a Tailer in New.
+type Option func(*options)
+
+type options struct {
+	cfg tailer.Config
+}
+
+// defaultOptions matches the ft command's defaults.
+func defaultOptions() *options {
+	return &options{cfg: tailer.Config{
+		N:            10,
+		ScanInterval: 5 * time.Second,
+	}}
+}
+
+// WithSources tails more files or directories next to the path given to
+// New. A Label replaces the path as the display prefix of its lines.
+func WithSources(sources ...Source) Option {
+	return func(o *options) { o.cfg.Sources = append(o.cfg.Sources, sources...) }
+}
+
+// WithInclude only tails files matching one of the globs. Globs containing
+// "/" match the path relative to the source, others the file name.
+func WithInclude(globs ...string) Option {
+	return func(o *options) { o.cfg.Include = append(o.cfg.Include, globs...) }
+}
+
+// WithExclude skips files matching one of the globs.
+func WithExclude(globs ...string) Option {
+	return func(o *options) { o.cfg.Exclude = append(o.cfg.Exclude, globs...) }
+}
+
+// WithRegexPatterns treats include and exclude patterns as regular
+// expressions instead of globs.
+func WithRegexPatterns() Option {
+	return func(o *options) { o.cfg.ForceRegex = true }
+}
+
+// WithRecursive controls whether subdirectories are tailed (default true).
+func WithRecursive(recursive bool) Option {
+	return func(o *options) {
+		o.cfg.Recursive = recursive
+		o.cfg.RecursiveSet = true
+	}
+}
+
+// WithLastLines sets how many existing lines of each file are emitted on
+// start (default 10; 0 starts at the end).
+func WithLastLines(n int) Option {
+	return func(o *options) { o.cfg.N = n }
+}
+
+// WithFromStart emits existing files from the beginning.
+func WithFromStart() Option {
+	return func(o *options) { o.cfg.FromStart = true }
+}
+
+// WithScanInterval sets how often the tree is rescanned for missed changes
+// (default 5s; 0 disables).
+func WithScanInterval(d time.Duration) Option {
+	return func(o *options) { o.cfg.ScanInterval = d }
+}
+
+// WithAbsolutePaths reports absolute paths in Line.Path.
+func WithAbsolutePaths() Option {
+	return func(o *options) { o.cfg.Absolute = true }
+}
+
+// WithMaxLineBytes truncates longer lines (default 1 MiB).
+func WithMaxLineBytes(n int) Option {
+	return func(o *options) { o.cfg.MaxLineBytes = n }
+}
+
+// WithGrep only emits lines matching one of the regular expressions.
+func WithGrep(patterns ...string) Option {
+	return func(o *options) { o.cfg.Grep = append(o.cfg.Grep, patterns...) }
+}
+
+// WithGrepExclude drops lines matching one of the regular expressions.
+func WithGrepExclude(patterns ...string) Option {
+	return func(o *options) { o.cfg.GrepExclude = append(o.cfg.GrepExclude, patterns...) }
+}
+
+// WithGrepFixed treats grep patterns as literal strings.
+func WithGrepFixed() Option {
+	return func(o *options) { o.cfg.GrepFixed = true }
+}
+
+// WithGrepIgnoreCase matches grep patterns case-insensitively.
+func WithGrepIgnoreCase() Option {
+	return func(o *options) { o.cfg.GrepIgnoreCase = true }
+}
+
+// WithGrepContext also emits up to before lines ahead of each grep match and
+// after lines following it, with a "--" marker line between groups.
+func WithGrepContext(before, after int) Option {
+	return func(o *options) {
+		o.cfg.GrepBefore = before
+		o.cfg.GrepAfter = after
+	}
+}
+
+// WithOverflow decides what happens when Lines() is full (default
+// OverflowDropOldest).
+func WithOverflow(policy OverflowPolicy) Option {
+	return func(o *options) { o.cfg.Overflow = policy }
+}
+
+// WithWatchMode picks the change detection backend (default WatchAuto).
+func WithWatchMode(mode WatchMode) Option {
+	return func(o *options) { o.cfg.Watch = mode }
+}
+
+// WithPollInterval sets how often polled directories are checked (default
+// 1s).
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) { o.cfg.PollInterval = d }
+}
+
+// WithFollowSymlinks follows links to files and directories.
+func WithFollowSymlinks() Option {
+	return func(o *options) { o.cfg.FollowSymlinks = true }
+}
+
+// WithTimeOrder merges lines from all files by their leading timestamp,
+// holding live lines for window (default 1s) to sort them. layout adds a Go
+// time layout to the recognized formats; it may be empty.
+func WithTimeOrder(window time.Duration, layout string) Option {
+	return func(o *options) {
+		o.cfg.Order = tailer.OrderTime
+		o.cfg.ReorderWindow = window
+		o.cfg.TimeLayout = layout
+	}
+}
+
+// WithMultilineStart groups lines into records that start at a line
+// matching the regular expression.
+func WithMultilineStart(pattern string) Option {
+	return func(o *options) { o.cfg.MultilineStart = pattern }
+}
+
+// WithMultilineIndent appends lines starting with whitespace to the
+// previous record.
+func WithMultilineIndent() Option {
+	return func(o *options) { o.cfg.MultilineIndent = true }
+}
+
+// WithMultilineTimeout emits a pending record after d without new lines
+// (default 500ms).
+func WithMultilineTimeout(d time.Duration) Option {
+	return func(o *options) { o.cfg.MultilineTimeout = d }
+}
+
+// WithRotatedBacklog fills a short initial backlog from rotated siblings
+// such as app.log.1 and app.log.2.gz.
+func WithRotatedBacklog() Option {
+	return func(o *options) { o.cfg.RotatedBacklog = true }
+}
+
+// WithEncoding overrides encoding detection, either for every file ("latin1")
+// or for matching files ("*.sjis.log=shift_jis").
+func WithEncoding(rules ...string) Option {
+	return func(o *options) { o.cfg.Encoding = append(o.cfg.Encoding, rules...) }
+}
+
+// WithANSI sets how escape sequences in Lines() are handled (default
+// ANSISanitize, for text written to a terminal). Subscribers always get the
+// file's text unmodified.
+func WithANSI(mode ANSIMode) Option {
+	return func(o *options) { o.cfg.ANSI = mode }
+}
+
+// WithMinLevel drops lines below level; lines without a level are kept.
+func WithMinLevel(level Level) Option {
+	return func(o *options) { o.cfg.MinLevel = level }
+}
+
+// WithFieldFormat sets which structured formats fill Line.Fields (default
+// FieldsAuto).
+func WithFieldFormat(format FieldFormat) Option {
+	return func(o *options) { o.cfg.Fields = format }
+}
+
+// WithWhere only emits lines matching a field expression such as
+// `level>=warn and latency_ms>500`.
+func WithWhere(expr string) Option {
+	return func(o *options) { o.cfg.Where = expr }
+}
+
+// WithCheckpoints saves each file's offset to path every interval (default
+// 5s) and on stop. With resume, files start from their saved offset.
+func WithCheckpoints(path string, interval time.Duration, resume bool) Option {
+	return func(o *options) {
+		o.cfg.CheckpointPath = path
+		o.cfg.CheckpointInterval = interval
+		o.cfg.Resume = resume
+	}
+}